@@ -0,0 +1,326 @@
+package clients
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/remote"
+)
+
+// Cache is a streams.Client wrapper that keeps recently loaded documents in a
+// bounded, in-memory LRU cache. Each document stays fresh for as long as the
+// HTTP caching headers (Cache-Control, Expires, Age) returned by the remote
+// server allow, and is reloaded from the inner client once it goes stale.
+//
+// Stale documents that include an ETag or Last-Modified header are revalidated
+// with a conditional GET (If-None-Match / If-Modified-Since). When the remote
+// server responds "304 Not Modified", the cached copy is returned with updated
+// freshness. Use CacheStatus to see how each document was produced.
+type Cache struct {
+	innerClient streams.Client
+	rootClient  streams.Client
+
+	maxEntries int           // Maximum number of documents to keep in memory
+	defaultTTL time.Duration // Lifetime of documents that have no caching headers
+	maxTTL     time.Duration // Upper limit for any document's lifetime (zero = no limit)
+	now        func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Front = most recently used
+	stats   CacheStats
+}
+
+// cacheEntry is a single document stored in the Cache
+type cacheEntry struct {
+	key      string
+	document streams.Document
+	expires  time.Time
+}
+
+// CacheStats reports how well a Cache is performing, so that it can be sized for production.
+type CacheStats struct {
+	Hits          int64 // Number of Load calls served from the cache
+	Misses        int64 // Number of Load calls passed through to the inner client
+	Revalidations int64 // Number of stale documents that the remote server confirmed were unchanged
+	Evictions     int64 // Number of documents pushed out of the cache to make room for others
+	Entries       int   // Number of documents currently in the cache
+}
+
+// NewCache creates a fully initialized Cache client
+func NewCache(innerClient streams.Client, options ...CacheOption) *Cache {
+
+	result := &Cache{
+		innerClient: innerClient,
+		maxEntries:  1024,
+		defaultTTL:  time.Hour,
+		maxTTL:      24 * time.Hour,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load returns a fresh document from the cache if one exists. Otherwise, it
+// loads the document from the inner client (revalidating the stale copy, if
+// possible) and caches the result.
+func (client *Cache) Load(url string, options ...any) (streams.Document, error) {
+
+	// Look for a fresh copy in the cache
+	cached, found, fresh := client.get(url)
+
+	if fresh {
+		return withCacheStatus(cached, CacheStatusHit), nil
+	}
+
+	// If we have a stale copy, then ask the remote server if it has changed
+	var responseHeader http.Header
+
+	if found {
+		options = append(options[:len(options):len(options)], conditionalRequest(cached.HTTPHeader(), &responseHeader))
+	}
+
+	// Fall through means we need to load the document from the inner client
+	document, err := client.innerClient.Load(url, options...)
+
+	if err != nil {
+
+		// "304 Not Modified" means the stale copy is still good
+		if found && (derp.ErrorCode(err) == http.StatusNotModified) {
+			return client.revalidate(url, cached, responseHeader), nil
+		}
+
+		return document, err
+	}
+
+	// Documents loaded through this cache should use the whole client chain for their own links
+	document.WithOptions(streams.WithClient(client.rootClient))
+
+	client.put(url, document)
+
+	if found {
+		return withCacheStatus(document.Clone(), CacheStatusRefetched), nil
+	}
+
+	return withCacheStatus(document.Clone(), CacheStatusMiss), nil
+}
+
+// Save adds a document to the cache (keyed by its ID), then passes it
+// to the inner client.
+func (client *Cache) Save(document streams.Document) error {
+
+	if documentID := document.ID(); documentID != "" {
+		client.put(documentID, document.Clone())
+	}
+
+	return client.innerClient.Save(document)
+}
+
+// Delete removes a document from the cache, then from the inner client.
+func (client *Cache) Delete(documentID string) error {
+
+	client.remove(documentID)
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient records the top-level client (applied to every document that
+// this cache returns) and passes it down to the inner client.
+func (client *Cache) SetRootClient(rootClient streams.Client) {
+
+	client.rootClient = rootClient
+
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+// Stats returns a snapshot of the cache's hit, miss, and eviction counters.
+func (client *Cache) Stats() CacheStats {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	result := client.stats
+	result.Entries = client.order.Len()
+	return result
+}
+
+// Clear removes every document from the cache. Counters are not reset.
+func (client *Cache) Clear() {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.entries = make(map[string]*list.Element)
+	client.order.Init()
+}
+
+/******************************************
+ * Internal Methods
+ ******************************************/
+
+// get returns a copy of a document from the cache (if one exists), and whether it is still fresh.
+// Stale documents that can be revalidated are returned; all other stale documents are removed.
+// Anything other than a fresh document is counted as a miss.
+func (client *Cache) get(key string) (document streams.Document, found bool, fresh bool) {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	element, ok := client.entries[key]
+
+	if !ok {
+		client.stats.Misses++
+		return streams.NilDocument(), false, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+
+	if !client.now().Before(entry.expires) {
+
+		client.stats.Misses++
+
+		if hasValidators(entry.document.HTTPHeader()) {
+			return entry.document.Clone(), true, false
+		}
+
+		client.removeElement(element)
+		return streams.NilDocument(), false, false
+	}
+
+	client.order.MoveToFront(element)
+	client.stats.Hits++
+	return entry.document.Clone(), true, true
+}
+
+// revalidate updates a stale document with the headers from a "304 Not Modified" response
+// (per RFC 9111, section 4.3.4), stores it again, and returns a copy.
+func (client *Cache) revalidate(key string, document streams.Document, responseHeader http.Header) streams.Document {
+
+	header := document.HTTPHeader()
+
+	for name, values := range responseHeader {
+		if name != "Content-Length" {
+			header[name] = values
+		}
+	}
+
+	client.put(key, document)
+
+	client.mutex.Lock()
+	client.stats.Revalidations++
+	client.mutex.Unlock()
+
+	return withCacheStatus(document.Clone(), CacheStatusRevalidated)
+}
+
+// put adds a document to the cache, evicting the least recently used documents if necessary.
+// Documents that the remote server has marked as uncacheable are not stored. Documents that are
+// already stale are stored only if they can be revalidated later.
+func (client *Cache) put(key string, document streams.Document) {
+
+	header := document.HTTPHeader()
+	lifetime, cacheable := freshnessLifetime(header, client.now(), client.defaultTTL)
+
+	if !cacheable || ((lifetime <= 0) && !hasValidators(header)) {
+		client.remove(key)
+		return
+	}
+
+	if (client.maxTTL > 0) && (lifetime > client.maxTTL) {
+		lifetime = client.maxTTL
+	}
+
+	// Cached documents outlive the request that loaded them, so they must not keep its context
+	document.WithOptions(streams.WithRequestContext(nil))
+
+	entry := &cacheEntry{
+		key:      key,
+		document: document,
+		expires:  client.now().Add(lifetime),
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	// Replace existing entries in place
+	if element, ok := client.entries[key]; ok {
+		element.Value = entry
+		client.order.MoveToFront(element)
+		return
+	}
+
+	client.entries[key] = client.order.PushFront(entry)
+
+	// Evict the least recently used documents until we're back under the limit
+	for client.order.Len() > client.maxEntries {
+		client.removeElement(client.order.Back())
+		client.stats.Evictions++
+	}
+}
+
+// remove removes a document from the cache, if it exists.
+func (client *Cache) remove(key string) {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if element, ok := client.entries[key]; ok {
+		client.removeElement(element)
+	}
+}
+
+// removeElement removes an element from the cache.  The caller must hold the mutex.
+func (client *Cache) removeElement(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	delete(client.entries, entry.key)
+	client.order.Remove(element)
+}
+
+// conditionalRequest returns a remote.Option that turns a request into a conditional GET, using
+// the validators (ETag and Last-Modified) from a cached document's headers. The headers of the
+// remote server's response are written into `responseHeader`.
+func conditionalRequest(cachedHeader http.Header, responseHeader *http.Header) remote.Option {
+
+	return remote.Option{
+
+		BeforeRequest: func(txn *remote.Transaction) error {
+
+			if etag := cachedHeader.Get("ETag"); etag != "" {
+				txn.Header("If-None-Match", etag)
+			}
+
+			if lastModified := cachedHeader.Get("Last-Modified"); lastModified != "" {
+				txn.Header("If-Modified-Since", lastModified)
+			}
+
+			return nil
+		},
+
+		AfterRequest: func(_ *remote.Transaction, response *http.Response) error {
+			if response != nil {
+				*responseHeader = response.Header
+			}
+			return nil
+		},
+	}
+}
+
+// hasValidators returns TRUE if a document can be revalidated with a conditional GET
+func hasValidators(header http.Header) bool {
+	return (header.Get("ETag") != "") || (header.Get("Last-Modified") != "")
+}
+
+// Verify that Cache satisfies the streams.Client interface.
+var _ streams.Client = &Cache{}
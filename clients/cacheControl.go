@@ -0,0 +1,96 @@
+package clients
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// freshnessLifetime calculates how long a document may be served from a cache, based on
+// the HTTP caching headers that were returned with it. If no caching headers are present,
+// then the defaultTTL is used. The second return value is FALSE if the document must
+// not be stored at all.
+// https://www.rfc-editor.org/rfc/rfc9111#name-calculating-freshness-lifet
+func freshnessLifetime(header http.Header, now time.Time, defaultTTL time.Duration) (time.Duration, bool) {
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+
+	// RULE: "no-store" documents are never cached
+	if _, ok := directives["no-store"]; ok {
+		return 0, false
+	}
+
+	// RULE: "no-cache" documents may be stored, but must be revalidated before every use
+	if _, ok := directives["no-cache"]; ok {
+		return 0, true
+	}
+
+	// Time that the document has already spent in other caches (proxies, CDNs)
+	age := parseSeconds(header.Get("Age"))
+
+	// Hannibal is a shared cache, so "s-maxage" takes priority over "max-age"
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[name]; ok {
+			return nonNegative(parseSeconds(value) - age), true
+		}
+	}
+
+	// Fall back to the "Expires" header, measured against the server's own clock when possible
+	if expiresHeader := header.Get("Expires"); expiresHeader != "" {
+
+		expires, err := http.ParseTime(expiresHeader)
+
+		// RULE: Invalid "Expires" values (like "0") mean that the document is already expired
+		if err != nil {
+			return 0, true
+		}
+
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+
+		return nonNegative(expires.Sub(now) - age), true
+	}
+
+	// No caching headers, so use the default lifetime
+	return defaultTTL, true
+}
+
+// parseCacheControl splits a Cache-Control header into a map of (lowercase) directive names and values
+func parseCacheControl(value string) map[string]string {
+
+	result := make(map[string]string)
+
+	for _, directive := range strings.Split(value, ",") {
+
+		name, argument, _ := strings.Cut(directive, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if name == "" {
+			continue
+		}
+
+		result[name] = strings.Trim(strings.TrimSpace(argument), `"`)
+	}
+
+	return result
+}
+
+// parseSeconds converts a delta-seconds header value into a time.Duration.
+// Invalid values are treated as zero.
+func parseSeconds(value string) time.Duration {
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// nonNegative returns the provided duration, or zero if it is negative
+func nonNegative(value time.Duration) time.Duration {
+	return max(value, 0)
+}
@@ -0,0 +1,34 @@
+package clients
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFreshnessLifetime confirms the lifetime calculated for each combination of caching headers.
+func TestFreshnessLifetime(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	check := func(description string, header http.Header, expectedLifetime time.Duration, expectedCacheable bool) {
+		lifetime, cacheable := freshnessLifetime(header, now, time.Hour)
+		assert.Equal(t, expectedLifetime, lifetime, description)
+		assert.Equal(t, expectedCacheable, cacheable, description)
+	}
+
+	check("no headers", http.Header{}, time.Hour, true)
+	check("no-store", http.Header{"Cache-Control": {"private, no-store"}}, 0, false)
+	check("no-cache", http.Header{"Cache-Control": {"no-cache"}}, 0, true)
+	check("max-age", http.Header{"Cache-Control": {"max-age=300"}}, 5*time.Minute, true)
+	check("quoted max-age", http.Header{"Cache-Control": {`max-age="300"`}}, 5*time.Minute, true)
+	check("s-maxage wins", http.Header{"Cache-Control": {"max-age=300, s-maxage=60"}}, time.Minute, true)
+	check("age", http.Header{"Cache-Control": {"max-age=300"}, "Age": {"100"}}, 200*time.Second, true)
+	check("age exceeds max-age", http.Header{"Cache-Control": {"max-age=300"}, "Age": {"500"}}, 0, true)
+	check("expires", http.Header{"Expires": {"Mon, 01 Jan 2024 12:10:00 GMT"}}, 10*time.Minute, true)
+	check("expires with date", http.Header{"Expires": {"Mon, 01 Jan 2024 12:10:00 GMT"}, "Date": {"Mon, 01 Jan 2024 12:05:00 GMT"}}, 5*time.Minute, true)
+	check("invalid expires", http.Header{"Expires": {"0"}}, 0, true)
+	check("max-age beats expires", http.Header{"Cache-Control": {"max-age=60"}, "Expires": {"Mon, 01 Jan 2024 12:10:00 GMT"}}, time.Minute, true)
+}
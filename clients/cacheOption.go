@@ -0,0 +1,32 @@
+package clients
+
+import "time"
+
+// CacheOption is a function that modifies a Cache client
+type CacheOption func(*Cache)
+
+// CacheMaxEntries sets the maximum number of documents that the Cache will hold
+// before evicting the least recently used ones. Values less than one are ignored.
+func CacheMaxEntries(maxEntries int) CacheOption {
+	return func(cache *Cache) {
+		if maxEntries > 0 {
+			cache.maxEntries = maxEntries
+		}
+	}
+}
+
+// CacheDefaultTTL sets how long to keep documents whose server did not
+// send any caching headers.
+func CacheDefaultTTL(ttl time.Duration) CacheOption {
+	return func(cache *Cache) {
+		cache.defaultTTL = ttl
+	}
+}
+
+// CacheMaxTTL sets the longest time that any document will be kept, regardless
+// of the caching headers sent by its server. Zero removes the limit.
+func CacheMaxTTL(ttl time.Duration) CacheOption {
+	return func(cache *Cache) {
+		cache.maxTTL = ttl
+	}
+}
@@ -0,0 +1,76 @@
+package clients
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/benpate/hannibal/streams"
+)
+
+// CacheStatusHit means the document was served from the cache without contacting the remote server
+const CacheStatusHit = "HIT"
+
+// CacheStatusMiss means the document was not in the cache, so it was loaded from the remote server
+const CacheStatusMiss = "MISS"
+
+// CacheStatusRevalidated means the cached copy was stale, and the remote server confirmed
+// that it has not changed ("304 Not Modified")
+const CacheStatusRevalidated = "REVALIDATED"
+
+// CacheStatusRefetched means the cached copy was stale, and was replaced with a new
+// version from the remote server
+const CacheStatusRefetched = "REFETCHED"
+
+// cacheStatusName identifies this cache in the RFC 9211 "Cache-Status" header
+const cacheStatusName = "hannibal"
+
+// cacheStatusParameters maps each status to its RFC 9211 "Cache-Status" parameters
+var cacheStatusParameters = map[string]string{
+	CacheStatusHit:         "hit",
+	CacheStatusMiss:        "fwd=uri-miss",
+	CacheStatusRevalidated: "fwd=stale; fwd-status=304",
+	CacheStatusRefetched:   "fwd=stale; fwd-status=200",
+}
+
+// CacheStatus returns how the (outermost) Cache produced a document: CacheStatusHit,
+// CacheStatusMiss, CacheStatusRevalidated, or CacheStatusRefetched. It returns an empty
+// string if the document was not loaded through a Cache.
+//
+// The status is read from the standard "Cache-Status" header (RFC 9211) that the
+// Cache adds to each document that it returns.
+func CacheStatus(document streams.Document) string {
+
+	// Use the last entry that a Cache added, which comes from the Cache closest to the caller
+	result := ""
+
+	for _, value := range document.HTTPHeader().Values("Cache-Status") {
+		for entry := range strings.SplitSeq(value, ",") {
+
+			name, parameters, _ := strings.Cut(strings.TrimSpace(entry), ";")
+
+			if strings.TrimSpace(name) != cacheStatusName {
+				continue
+			}
+
+			for status, expected := range cacheStatusParameters {
+				if strings.TrimSpace(parameters) == expected {
+					result = status
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// withCacheStatus adds a "Cache-Status" header entry to a document that is being returned
+func withCacheStatus(document streams.Document, status string) streams.Document {
+
+	// Documents without a header (such as those added via Save) need one before we can add to it
+	if document.HTTPHeader() == nil {
+		document.SetHTTPHeader(http.Header{})
+	}
+
+	document.HTTPHeader().Add("Cache-Status", cacheStatusName+"; "+cacheStatusParameters[status])
+	return document
+}
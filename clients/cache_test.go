@@ -0,0 +1,340 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheTestDocument returns a document with the provided ID and HTTP headers
+func cacheTestDocument(id string, header http.Header) streams.Document {
+	return streams.NewDocument(
+		map[string]any{vocab.PropertyID: id, vocab.PropertyType: vocab.ObjectTypeNote},
+		streams.WithHTTPHeader(header),
+	)
+}
+
+// TestCache_Hit confirms a second Load of the same URL is served from memory.
+func TestCache_Hit(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client := NewCache(inner)
+
+	first, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", first.ID())
+
+	second, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", second.ID())
+
+	assert.Equal(t, 1, inner.loadCount)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Entries: 1}, client.Stats())
+}
+
+// TestCache_ReturnsCopies confirms callers cannot modify the cached document.
+func TestCache_ReturnsCopies(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client := NewCache(inner)
+
+	first, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	first.SetProperty(vocab.PropertyName, "changed")
+
+	second, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "", second.Name())
+}
+
+// TestCache_UsesRootClient confirms cached documents load their links through the whole client chain.
+func TestCache_UsesRootClient(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client := NewCache(inner)
+
+	document, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Same(t, client, document.Client())
+	assert.True(t, inner.rootClientSet)
+}
+
+// TestCache_MaxAge confirms documents expire according to Cache-Control and Age headers.
+func TestCache_MaxAge(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{
+		"Cache-Control": {"public, max-age=120"},
+		"Age":           {"60"},
+	})}
+
+	client := NewCache(inner)
+	client.now = func() time.Time { return now }
+
+	_, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+
+	// Still fresh after 59 seconds
+	now = now.Add(59 * time.Second)
+	_, err = client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, inner.loadCount)
+
+	// Stale after 60 seconds (120 max-age minus 60 seconds already spent in upstream caches)
+	now = now.Add(time.Second)
+	_, err = client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.loadCount)
+}
+
+// TestCache_NoStore confirms "no-store" documents are never cached.
+func TestCache_NoStore(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{
+		"Cache-Control": {"no-store"},
+	})}
+	client := NewCache(inner)
+
+	_, _ = client.Load("https://example.com/1")
+	_, _ = client.Load("https://example.com/1")
+
+	assert.Equal(t, 2, inner.loadCount)
+	assert.Equal(t, 0, client.Stats().Entries)
+}
+
+// TestCache_Eviction confirms the least recently used document is evicted first.
+func TestCache_Eviction(t *testing.T) {
+
+	inner := &mockInnerClient{}
+	client := NewCache(inner, CacheMaxEntries(2))
+
+	load := func(id string) {
+		inner.loadResult = cacheTestDocument(id, http.Header{})
+		_, err := client.Load(id)
+		require.NoError(t, err)
+	}
+
+	load("https://example.com/1")
+	load("https://example.com/2")
+	load("https://example.com/1") // hit: 1 is now the most recently used
+	load("https://example.com/3") // evicts 2
+
+	assert.Equal(t, 3, inner.loadCount)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Entries: 2}, client.Stats())
+
+	load("https://example.com/2")
+	assert.Equal(t, 4, inner.loadCount)
+}
+
+// TestCache_SaveAndDelete confirms Save populates the cache and Delete evicts from it,
+// and that both pass through to the inner client.
+func TestCache_SaveAndDelete(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: errors.New("should not be called")}
+	client := NewCache(inner)
+
+	require.NoError(t, client.Save(cacheTestDocument("https://example.com/1", http.Header{})))
+	assert.Equal(t, "https://example.com/1", inner.savedDocument.ID())
+
+	document, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", document.ID())
+	assert.Equal(t, 0, inner.loadCount)
+
+	require.NoError(t, client.Delete("https://example.com/1"))
+	assert.Equal(t, "https://example.com/1", inner.deletedID)
+
+	_, err = client.Load("https://example.com/1")
+	require.Error(t, err)
+	assert.Equal(t, 1, inner.loadCount)
+}
+
+// TestCache_SaveWithoutHeader confirms documents saved without an HTTP header
+// can be loaded from the cache, and report their cache status.
+func TestCache_SaveWithoutHeader(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: errors.New("should not be called")}
+	client := NewCache(inner)
+
+	require.NoError(t, client.Save(cacheTestDocument("https://example.com/1", nil)))
+
+	document, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", document.ID())
+	assert.Equal(t, CacheStatusHit, CacheStatus(document))
+	assert.Equal(t, 0, inner.loadCount)
+
+	// The status header must not leak back into the cached copy
+	document, err = client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Len(t, document.HTTPHeader().Values("Cache-Status"), 1)
+}
+
+// TestCache_DropsRequestContext confirms cached documents do not keep the context of the
+// request that stored them, so later readers are not cancelled along with it.
+func TestCache_DropsRequestContext(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: errors.New("should not be called")}
+	client := NewCache(inner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	document := cacheTestDocument("https://example.com/1", http.Header{})
+	document.WithOptions(streams.WithRequestContext(ctx))
+	require.NoError(t, client.Save(document))
+
+	cached, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	require.NoError(t, cached.RequestContext().Err())
+}
+
+// TestCache_InnerError confirms errors are returned and not cached.
+func TestCache_InnerError(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: errors.New("network failure")}
+	client := NewCache(inner)
+
+	_, err := client.Load("https://example.com/1")
+	require.Error(t, err)
+	assert.Equal(t, 0, client.Stats().Entries)
+}
+
+// newRevalidationServer returns a test server that supports conditional GETs with an ETag
+// that can be changed, and the number of full (200 OK) responses that it has sent.
+func newRevalidationServer(t *testing.T, etag *string) (*httptest.Server, *int) {
+
+	fullResponses := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("ETag", *etag)
+		w.Header().Set("Cache-Control", "max-age=0")
+
+		if r.Header.Get("If-None-Match") == *etag {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		fullResponses++
+		w.Header().Set("Content-Type", vocab.ContentTypeActivityPub)
+		_, _ = w.Write([]byte(`{"id":"https://example.com/1","type":"Note","content":"` + strings.Trim(*etag, `"`) + `"}`))
+	}))
+
+	t.Cleanup(server.Close)
+	return server, &fullResponses
+}
+
+// newRevalidationCache returns a Cache that wraps a DefaultClient that can reach local test servers
+func newRevalidationCache() *Cache {
+
+	allowPrivateIPs := remote.Option{
+		BeforeRequest: func(txn *remote.Transaction) error {
+			txn.AllowPrivateIPs(true)
+			return nil
+		},
+	}
+
+	return NewCache(streams.NewDefaultClient(allowPrivateIPs))
+}
+
+// TestCache_Revalidate confirms stale documents are revalidated with a conditional GET,
+// and that a "304 Not Modified" response refreshes the cached copy.
+func TestCache_Revalidate(t *testing.T) {
+
+	etag := `"v1"`
+	server, fullResponses := newRevalidationServer(t, &etag)
+	client := newRevalidationCache()
+
+	// First request loads the whole document, which is stale right away (max-age=0)
+	document, err := client.Load(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStatusMiss, CacheStatus(document))
+
+	// Second request is revalidated. The 304 response makes the document fresh for a minute
+	document, err = client.Load(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStatusRevalidated, CacheStatus(document))
+	assert.Equal(t, "v1", document.Content())
+	assert.Equal(t, "max-age=60", document.HTTPHeader().Get("Cache-Control"))
+
+	// Third request is served from memory
+	document, err = client.Load(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStatusHit, CacheStatus(document))
+
+	assert.Equal(t, 1, *fullResponses)
+	assert.Equal(t, int64(1), client.Stats().Revalidations)
+}
+
+// TestCache_Refetch confirms a stale document is replaced when the remote server has a new version.
+func TestCache_Refetch(t *testing.T) {
+
+	etag := `"v1"`
+	server, fullResponses := newRevalidationServer(t, &etag)
+	client := newRevalidationCache()
+
+	_, err := client.Load(server.URL)
+	require.NoError(t, err)
+
+	etag = `"v2"`
+
+	document, err := client.Load(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStatusRefetched, CacheStatus(document))
+	assert.Equal(t, "v2", document.Content())
+	assert.Equal(t, 2, *fullResponses)
+}
+
+// TestCache_StaleWithoutValidators confirms stale documents without an ETag or Last-Modified header are dropped.
+func TestCache_StaleWithoutValidators(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{
+		"Cache-Control": {"no-cache"},
+	})}
+	client := NewCache(inner)
+
+	document, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, CacheStatusMiss, CacheStatus(document))
+	assert.Equal(t, 0, client.Stats().Entries)
+
+	// Stale documents with validators are kept for revalidation
+	inner.loadResult = cacheTestDocument("https://example.com/1", http.Header{
+		"Cache-Control": {"no-cache"},
+		"Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"},
+	})
+
+	_, err = client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, client.Stats().Entries)
+
+	// No remote.Options are added for documents that cannot be revalidated
+	assert.Empty(t, inner.lastLoadOpts)
+}
+
+// TestCacheStatus confirms the status is read from this cache's entry in the Cache-Status header.
+func TestCacheStatus(t *testing.T) {
+
+	check := func(expected string, values ...string) {
+		assert.Equal(t, expected, CacheStatus(cacheTestDocument("https://example.com/1", http.Header{"Cache-Status": values})), values)
+	}
+
+	check("")
+	check("", "ExampleCDN; hit")
+	check(CacheStatusHit, "ExampleCDN; fwd=uri-miss, hannibal; hit")
+	check(CacheStatusRevalidated, "ExampleCDN; hit", "hannibal; fwd=stale; fwd-status=304")
+	check(CacheStatusMiss, "hannibal; hit", "hannibal; fwd=uri-miss")
+}
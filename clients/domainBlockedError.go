@@ -0,0 +1,30 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DomainBlockedError is returned by the DomainFilter client when a document is not loaded
+// because its host is blocked (or is not on the allow list). It has its own error code so
+// that applications can explain "this instance is blocked" instead of a generic failure.
+type DomainBlockedError struct {
+	Host string // Host name that was refused
+}
+
+// Error implements the error interface
+func (err DomainBlockedError) Error() string {
+	return "hannibal.clients.DomainFilter: requests to " + err.Host + " are blocked"
+}
+
+// ErrorCode returns HTTP 451 (Unavailable For Legal Reasons), which is reserved for
+// blocked domains so that derp.ErrorCode can tell them apart from other failures.
+func (err DomainBlockedError) ErrorCode() int {
+	return http.StatusUnavailableForLegalReasons
+}
+
+// IsDomainBlockedError returns TRUE if the provided error (or any error that it wraps) is a DomainBlockedError
+func IsDomainBlockedError(err error) bool {
+	var domainBlockedError DomainBlockedError
+	return errors.As(err, &domainBlockedError)
+}
@@ -0,0 +1,203 @@
+package clients
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/benpate/hannibal/streams"
+)
+
+// DomainFilter is a streams.Client wrapper that enforces instance-level defederation on outbound
+// requests. It refuses to load documents from hosts on its block list and, in allow-list-only
+// mode (for closed federations), from any host that is not on its allow list. Refused requests
+// return a DomainBlockedError, and never reach the inner client.
+//
+// Domains match their host name exactly ("example.com"), or with a wildcard ("*.example.com")
+// that matches the domain itself and all of its subdomains. Rules can be replaced at any time.
+type DomainFilter struct {
+	innerClient streams.Client
+
+	mutex         sync.RWMutex
+	blocked       domainRules // Hosts that are always refused
+	allowed       domainRules // Hosts that are permitted in allow-list-only mode
+	allowListOnly bool        // If TRUE, only hosts on the allow list can be loaded
+}
+
+// domainRules is a set of exact and wildcard domain names
+type domainRules struct {
+	exact    map[string]struct{}
+	wildcard map[string]struct{}
+}
+
+// NewDomainFilter creates a fully initialized DomainFilter client
+func NewDomainFilter(innerClient streams.Client, options ...DomainFilterOption) *DomainFilter {
+
+	result := &DomainFilter{
+		innerClient: innerClient,
+		blocked:     newDomainRules(nil),
+		allowed:     newDomainRules(nil),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load retrieves a document from the inner client, unless its host is blocked. The inner client
+// may follow redirects to other hosts, so the final URL of the request, and the ID of the
+// document that it returns, are checked as well.
+func (client *DomainFilter) Load(url string, options ...any) (streams.Document, error) {
+
+	if !client.IsAllowed(url) {
+		return streams.NilDocument(), DomainBlockedError{Host: hostnameOf(url)}
+	}
+
+	finalURL := url
+	options = append(options[:len(options):len(options)], captureFinalURL(&finalURL))
+
+	document, err := client.innerClient.Load(url, options...)
+
+	if err != nil {
+		return document, err
+	}
+
+	// RULE: Redirects cannot lead to blocked hosts
+	if !client.IsAllowed(finalURL) {
+		return streams.NilDocument(), DomainBlockedError{Host: hostnameOf(finalURL)}
+	}
+
+	// RULE: Allowed hosts cannot serve documents from blocked hosts
+	if documentID := document.ID(); (documentID != "") && !client.IsAllowed(documentID) {
+		return streams.NilDocument(), DomainBlockedError{Host: hostnameOf(documentID)}
+	}
+
+	return document, nil
+}
+
+// Save passes the document to the inner client.
+func (client *DomainFilter) Save(document streams.Document) error {
+	return client.innerClient.Save(document)
+}
+
+// Delete passes the document ID to the inner client.
+func (client *DomainFilter) Delete(documentID string) error {
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient passes the top-level client down to the underlying client.
+func (client *DomainFilter) SetRootClient(rootClient streams.Client) {
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+// IsAllowed returns TRUE if documents can be loaded from the provided URL under the current rules.
+func (client *DomainFilter) IsAllowed(url string) bool {
+
+	hostname := strings.TrimSuffix(hostnameOf(url), ".")
+
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	if client.blocked.matches(hostname) {
+		return false
+	}
+
+	if client.allowListOnly {
+		return client.allowed.matches(hostname)
+	}
+
+	return true
+}
+
+// SetBlocked replaces the block list. Domains can include a leading
+// wildcard ("*.example.com") to match all subdomains.
+func (client *DomainFilter) SetBlocked(domains ...string) {
+
+	rules := newDomainRules(domains)
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.blocked = rules
+}
+
+// SetAllowed replaces the allow list, which is used only in allow-list-only mode.
+// Domains can include a leading wildcard ("*.example.com") to match all subdomains.
+func (client *DomainFilter) SetAllowed(domains ...string) {
+
+	rules := newDomainRules(domains)
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.allowed = rules
+}
+
+// SetAllowListOnly turns allow-list-only mode on or off. In this mode, documents
+// can only be loaded from hosts on the allow list (and not on the block list).
+func (client *DomainFilter) SetAllowListOnly(allowListOnly bool) {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.allowListOnly = allowListOnly
+}
+
+// newDomainRules parses a list of domain names into a domainRules set. Domains are
+// case-insensitive, and blank values are ignored.
+func newDomainRules(domains []string) domainRules {
+
+	result := domainRules{
+		exact:    make(map[string]struct{}),
+		wildcard: make(map[string]struct{}),
+	}
+
+	for _, domain := range domains {
+
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+
+		if wildcard, isWildcard := strings.CutPrefix(domain, "*."); isWildcard {
+			if wildcard != "" {
+				result.wildcard[wildcard] = struct{}{}
+			}
+			continue
+		}
+
+		if domain != "" {
+			result.exact[domain] = struct{}{}
+		}
+	}
+
+	return result
+}
+
+// matches returns TRUE if the host name matches any of the rules
+func (rules domainRules) matches(hostname string) bool {
+
+	if hostname == "" {
+		return false
+	}
+
+	if _, ok := rules.exact[hostname]; ok {
+		return true
+	}
+
+	// Check the host name, and each of its parent domains, against the wildcards
+	for domain := hostname; domain != ""; {
+
+		if _, ok := rules.wildcard[domain]; ok {
+			return true
+		}
+
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+
+	return false
+}
+
+// Verify that DomainFilter satisfies the streams.Client interface.
+var _ streams.Client = &DomainFilter{}
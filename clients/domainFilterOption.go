@@ -0,0 +1,25 @@
+package clients
+
+// DomainFilterOption is a function that modifies a DomainFilter client
+type DomainFilterOption func(*DomainFilter)
+
+// DomainFilterBlocked sets the initial block list
+func DomainFilterBlocked(domains ...string) DomainFilterOption {
+	return func(client *DomainFilter) {
+		client.blocked = newDomainRules(domains)
+	}
+}
+
+// DomainFilterAllowed sets the initial allow list, which is used only in allow-list-only mode
+func DomainFilterAllowed(domains ...string) DomainFilterOption {
+	return func(client *DomainFilter) {
+		client.allowed = newDomainRules(domains)
+	}
+}
+
+// DomainFilterAllowListOnly sets whether documents can only be loaded from hosts on the allow list
+func DomainFilterAllowListOnly(allowListOnly bool) DomainFilterOption {
+	return func(client *DomainFilter) {
+		client.allowListOnly = allowListOnly
+	}
+}
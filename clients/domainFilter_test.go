@@ -0,0 +1,155 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDomainFilter_Blocked confirms blocked hosts are refused with a DomainBlockedError.
+func TestDomainFilter_Blocked(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client := NewDomainFilter(inner, DomainFilterBlocked("blocked.example", "*.evil.example"))
+
+	_, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+
+	_, err = client.Load("https://BLOCKED.example/users/alice")
+	require.Error(t, err)
+	assert.True(t, IsDomainBlockedError(err))
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, derp.ErrorCode(err))
+
+	// Wildcards match the domain itself, and every subdomain
+	_, err = client.Load("https://evil.example/1")
+	assert.True(t, IsDomainBlockedError(err))
+
+	_, err = client.Load("https://social.evil.example:8443/1")
+	assert.True(t, IsDomainBlockedError(err))
+
+	// Exact names do not match subdomains, and similar names do not match at all
+	_, err = client.Load("https://sub.blocked.example/1")
+	assert.NoError(t, err)
+
+	_, err = client.Load("https://notevil.example/1")
+	assert.NoError(t, err)
+
+	// Refused requests never reach the inner client
+	assert.Equal(t, 3, inner.loadCount)
+}
+
+// TestDomainFilter_AllowListOnly confirms only allowed hosts can be loaded in allow-list-only mode,
+// and that the block list still wins.
+func TestDomainFilter_AllowListOnly(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client := NewDomainFilter(inner,
+		DomainFilterAllowListOnly(true),
+		DomainFilterAllowed("*.friends.example", "partner.example"),
+		DomainFilterBlocked("bad.friends.example"),
+	)
+
+	assert.True(t, client.IsAllowed("https://friends.example/1"))
+	assert.True(t, client.IsAllowed("https://a.friends.example/1"))
+	assert.True(t, client.IsAllowed("https://partner.example./1"))
+
+	assert.False(t, client.IsAllowed("https://bad.friends.example/1"))
+	assert.False(t, client.IsAllowed("https://stranger.example/1"))
+	assert.False(t, client.IsAllowed("not a url"))
+
+	_, err := client.Load("https://stranger.example/1")
+	assert.True(t, IsDomainBlockedError(err))
+}
+
+// TestDomainFilter_Reload confirms rules can be replaced while the client is in use.
+func TestDomainFilter_Reload(t *testing.T) {
+
+	client := NewDomainFilter(&mockInnerClient{})
+	assert.True(t, client.IsAllowed("https://example.com/1"))
+
+	client.SetBlocked("example.com")
+	assert.False(t, client.IsAllowed("https://example.com/1"))
+
+	client.SetBlocked()
+	assert.True(t, client.IsAllowed("https://example.com/1"))
+
+	client.SetAllowListOnly(true)
+	assert.False(t, client.IsAllowed("https://example.com/1"))
+
+	client.SetAllowed("  Example.COM ")
+	assert.True(t, client.IsAllowed("https://example.com/1"))
+
+	// Reloading is safe alongside concurrent checks
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 100 {
+			client.SetBlocked("*.example.com")
+		}
+	})
+	wg.Go(func() {
+		for range 100 {
+			client.IsAllowed("https://example.com/1")
+		}
+	})
+	wg.Wait()
+}
+
+// TestDomainFilter_Redirect confirms redirects from an allowed host to a blocked host are refused.
+func TestDomainFilter_Redirect(t *testing.T) {
+
+	var target *httptest.Server
+	target = newOriginTestServer(t, func(r *http.Request) string {
+		// The ID is on an allowed host, so only the final URL reveals the blocked host
+		return `{"id":"` + target.URL + `/notes/1","type":"Note"}`
+	})
+
+	// Redirect to the same server, by a name that is blocked
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1)+"/notes/1", http.StatusFound)
+	}))
+	t.Cleanup(redirect.Close)
+
+	client := NewDomainFilter(newOriginTestClient().innerClient, DomainFilterBlocked("localhost"))
+
+	_, err := client.Load(redirect.URL + "/notes/1")
+	require.Error(t, err)
+	assert.True(t, IsDomainBlockedError(err))
+}
+
+// TestDomainFilter_DocumentID confirms allowed hosts cannot serve documents that belong to blocked hosts.
+func TestDomainFilter_DocumentID(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://blocked.example/1", http.Header{})}
+	client := NewDomainFilter(inner, DomainFilterBlocked("blocked.example"))
+
+	_, err := client.Load("https://example.com/1")
+	require.Error(t, err)
+	assert.True(t, IsDomainBlockedError(err))
+}
+
+// TestDomainFilter_LinkedThroughCache confirms linked documents are loaded through a filter that
+// wraps a cache, so blocked links are refused even after the parent document was cached.
+func TestDomainFilter_LinkedThroughCache(t *testing.T) {
+
+	document := cacheTestDocument("https://example.com/1", http.Header{})
+	document.SetProperty(vocab.PropertyAttributedTo, "https://blocked.example/users/alice")
+
+	inner := &mockInnerClient{loadResult: document}
+	client := NewDomainFilter(NewCache(inner), DomainFilterBlocked("blocked.example"))
+
+	loaded, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Same(t, client, loaded.Client())
+
+	_, err = loaded.AttributedTo().Load()
+	require.Error(t, err)
+	assert.True(t, IsDomainBlockedError(err))
+	assert.Equal(t, 1, inner.loadCount, "blocked links must not reach the cache or the inner client")
+}
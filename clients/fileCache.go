@@ -0,0 +1,275 @@
+package clients
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/metadata"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+)
+
+// FileCache is a streams.Client wrapper that persists loaded documents (along with their
+// metadata and HTTP headers) as JSON files in a local directory, so that they survive
+// restarts. It also remembers deleted documents -- `Tombstone` objects and `410 Gone`
+// responses -- so that they are not fetched again.
+type FileCache struct {
+	innerClient streams.Client
+	rootClient  streams.Client
+
+	directory    string        // Directory where documents are stored
+	defaultTTL   time.Duration // Lifetime of documents that have no caching headers
+	maxTTL       time.Duration // Upper limit for any document's lifetime (zero = no limit)
+	tombstoneTTL time.Duration // Lifetime of deleted documents (zero = forever)
+	now          func() time.Time
+}
+
+// fileCacheRecord is the on-disk representation of a single cached document
+type fileCacheRecord struct {
+	URL      string            `json:"url"`
+	Value    any               `json:"value,omitempty"`
+	Metadata metadata.Metadata `json:"metadata"`
+	Header   http.Header       `json:"header,omitempty"`
+	Gone     bool              `json:"gone,omitempty"`   // TRUE if the document has been deleted
+	Expires  time.Time         `json:"expires,omitzero"` // Zero value means the record never expires
+}
+
+// NewFileCache creates a fully initialized FileCache client that stores documents in the
+// provided directory. The directory is created when the first document is written.
+func NewFileCache(innerClient streams.Client, directory string, options ...FileCacheOption) *FileCache {
+
+	result := &FileCache{
+		innerClient:  innerClient,
+		directory:    directory,
+		defaultTTL:   24 * time.Hour,
+		maxTTL:       30 * 24 * time.Hour,
+		tombstoneTTL: 0,
+		now:          time.Now,
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load returns a document from the local directory if a fresh copy exists. Otherwise, it
+// loads the document from the inner client and writes the result to disk. Documents that
+// are known to be deleted return a NotFound error (for 410 Gone responses) or their
+// Tombstone, without contacting the remote server.
+func (client *FileCache) Load(url string, options ...any) (streams.Document, error) {
+
+	const location = "hannibal.clients.FileCache.Load"
+
+	// Look for a fresh copy on disk
+	if record, ok := client.read(url); ok {
+
+		if record.Gone && (record.Value == nil) {
+			return streams.NilDocument(), derp.NotFound(location, "Document has been deleted", url)
+		}
+
+		return client.document(record), nil
+	}
+
+	// Fall through means we need to load the document from the inner client
+	document, err := client.innerClient.Load(url, options...)
+
+	if err != nil {
+
+		// Remember documents that the remote server says are gone
+		if derp.ErrorCode(err) == http.StatusGone {
+			if writeError := client.write(url, fileCacheRecord{URL: url, Gone: true}, client.tombstoneTTL); writeError != nil {
+				derp.Report(derp.Wrap(writeError, location, "Unable to record deleted document", url))
+			}
+		}
+
+		return document, err
+	}
+
+	// Documents loaded through this cache should use the whole client chain for their own links
+	document.WithOptions(streams.WithClient(client.rootClient))
+
+	if err := client.save(url, document); err != nil {
+		derp.Report(derp.Wrap(err, location, "Unable to write document to cache", url))
+	}
+
+	return document, nil
+}
+
+// Save writes a document to the local directory (keyed by its ID), then passes it
+// to the inner client.
+func (client *FileCache) Save(document streams.Document) error {
+
+	const location = "hannibal.clients.FileCache.Save"
+
+	if documentID := document.ID(); documentID != "" {
+		if err := client.save(documentID, document); err != nil {
+			return derp.Wrap(err, location, "Unable to write document to cache", documentID)
+		}
+	}
+
+	return client.innerClient.Save(document)
+}
+
+// Delete removes a document from the local directory, then from the inner client.
+// Call this when an inbound `Delete` activity arrives, so that the stale copy is not used again.
+func (client *FileCache) Delete(documentID string) error {
+
+	const location = "hannibal.clients.FileCache.Delete"
+
+	if err := os.Remove(client.filename(documentID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return derp.Wrap(err, location, "Unable to remove document from cache", documentID)
+	}
+
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient records the top-level client (applied to every document that
+// this cache returns) and passes it down to the inner client.
+func (client *FileCache) SetRootClient(rootClient streams.Client) {
+
+	client.rootClient = rootClient
+
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+/******************************************
+ * Internal Methods
+ ******************************************/
+
+// save converts a document into a fileCacheRecord and writes it to disk.
+// Tombstones are stored as deleted records.
+func (client *FileCache) save(key string, document streams.Document) error {
+
+	record := fileCacheRecord{
+		URL:      key,
+		Value:    document.Value(),
+		Metadata: document.Metadata,
+		Header:   document.HTTPHeader(),
+	}
+
+	if document.Type() == vocab.ObjectTypeTombstone {
+		record.Gone = true
+		return client.write(key, record, client.tombstoneTTL)
+	}
+
+	lifetime, cacheable := freshnessLifetime(record.Header, client.now(), client.defaultTTL)
+
+	if !cacheable || lifetime <= 0 {
+		return nil
+	}
+
+	if (client.maxTTL > 0) && (lifetime > client.maxTTL) {
+		lifetime = client.maxTTL
+	}
+
+	return client.write(key, record, lifetime)
+}
+
+// write stores a record on disk. A zero lifetime means that the record never expires.
+// Files are written to a temporary name first, then renamed, so that readers never
+// see a partially written document.
+func (client *FileCache) write(key string, record fileCacheRecord, lifetime time.Duration) error {
+
+	const location = "hannibal.clients.FileCache.write"
+
+	if lifetime > 0 {
+		record.Expires = client.now().Add(lifetime)
+	}
+
+	data, err := json.Marshal(record)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to marshal document", key)
+	}
+
+	filename := client.filename(key)
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0o750); err != nil {
+		return derp.Wrap(err, location, "Unable to create cache directory", filename)
+	}
+
+	temporary, err := os.CreateTemp(filepath.Dir(filename), "*.tmp")
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to create temporary file", filename)
+	}
+
+	// Clean up the temporary file if anything goes wrong. (No-op after a successful rename)
+	defer os.Remove(temporary.Name())
+
+	if _, err := temporary.Write(data); err != nil {
+		_ = temporary.Close()
+		return derp.Wrap(err, location, "Unable to write temporary file", filename)
+	}
+
+	if err := temporary.Close(); err != nil {
+		return derp.Wrap(err, location, "Unable to close temporary file", filename)
+	}
+
+	if err := os.Rename(temporary.Name(), filename); err != nil {
+		return derp.Wrap(err, location, "Unable to rename temporary file", filename)
+	}
+
+	return nil
+}
+
+// read returns the unexpired record for the provided key, if one exists.
+// Expired and unreadable records are removed.
+func (client *FileCache) read(key string) (fileCacheRecord, bool) {
+
+	filename := client.filename(key)
+	data, err := os.ReadFile(filename)
+
+	if err != nil {
+		return fileCacheRecord{}, false
+	}
+
+	record := fileCacheRecord{}
+
+	if err := json.Unmarshal(data, &record); err != nil || (record.URL != key) {
+		_ = os.Remove(filename)
+		return fileCacheRecord{}, false
+	}
+
+	if !record.Expires.IsZero() && !client.now().Before(record.Expires) {
+		_ = os.Remove(filename)
+		return fileCacheRecord{}, false
+	}
+
+	return record, true
+}
+
+// document converts a record back into a streams.Document
+func (client *FileCache) document(record fileCacheRecord) streams.Document {
+	return streams.NewDocument(
+		record.Value,
+		streams.WithClient(client.rootClient),
+		streams.WithMetadata(record.Metadata),
+		streams.WithHTTPHeader(record.Header),
+	)
+}
+
+// filename returns the location on disk for the provided key. Files are spread
+// across subdirectories (named for the first two characters of the hash) so that
+// no single directory grows too large.
+func (client *FileCache) filename(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(hash[:])
+	return filepath.Join(client.directory, name[:2], name+".json")
+}
+
+// Verify that FileCache satisfies the streams.Client interface.
+var _ streams.Client = &FileCache{}
@@ -0,0 +1,30 @@
+package clients
+
+import "time"
+
+// FileCacheOption is a function that modifies a FileCache client
+type FileCacheOption func(*FileCache)
+
+// FileCacheDefaultTTL sets how long to keep documents whose server did not
+// send any caching headers.
+func FileCacheDefaultTTL(ttl time.Duration) FileCacheOption {
+	return func(cache *FileCache) {
+		cache.defaultTTL = ttl
+	}
+}
+
+// FileCacheMaxTTL sets the longest time that any document will be kept, regardless
+// of the caching headers sent by its server. Zero removes the limit.
+func FileCacheMaxTTL(ttl time.Duration) FileCacheOption {
+	return func(cache *FileCache) {
+		cache.maxTTL = ttl
+	}
+}
+
+// FileCacheTombstoneTTL sets how long to remember that a document has been deleted.
+// Zero (the default) remembers deleted documents forever.
+func FileCacheTombstoneTTL(ttl time.Duration) FileCacheOption {
+	return func(cache *FileCache) {
+		cache.tombstoneTTL = ttl
+	}
+}
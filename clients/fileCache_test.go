@@ -0,0 +1,130 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/metadata"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goneError is an error that reports an HTTP 410 Gone status code to derp.ErrorCode
+type goneError struct{}
+
+func (goneError) Error() string  { return "gone" }
+func (goneError) ErrorCode() int { return http.StatusGone }
+
+// TestFileCache_SurvivesRestart confirms documents (with metadata and headers) are
+// read back from disk by a new FileCache pointed at the same directory.
+func TestFileCache_SurvivesRestart(t *testing.T) {
+
+	directory := t.TempDir()
+
+	document := streams.NewDocument(
+		map[string]any{vocab.PropertyID: "https://example.com/1", vocab.PropertyType: vocab.ObjectTypeNote},
+		streams.WithHTTPHeader(http.Header{"Content-Type": {vocab.ContentTypeActivityPub}}),
+		streams.WithMetadata(metadata.Metadata{DocumentCategory: "Object", Likes: 7}),
+	)
+
+	inner := &mockInnerClient{loadResult: document}
+	_, err := NewFileCache(inner, directory).Load("https://example.com/1")
+	require.NoError(t, err)
+
+	// A new client (after a "restart") should not hit the inner client
+	inner = &mockInnerClient{loadErr: errors.New("should not be called")}
+	client := NewFileCache(inner, directory)
+
+	result, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, inner.loadCount)
+	assert.Equal(t, "https://example.com/1", result.ID())
+	assert.Equal(t, vocab.ObjectTypeNote, result.Type())
+	assert.Equal(t, int64(7), result.Metadata.Likes)
+	assert.Equal(t, vocab.ContentTypeActivityPub, result.HTTPHeader().Get("Content-Type"))
+	assert.Same(t, client, result.Client())
+}
+
+// TestFileCache_Expires confirms stale documents are reloaded from the inner client.
+func TestFileCache_Expires(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{
+		"Cache-Control": {"max-age=60"},
+	})}
+
+	client := NewFileCache(inner, t.TempDir())
+	client.now = func() time.Time { return now }
+
+	_, _ = client.Load("https://example.com/1")
+	_, _ = client.Load("https://example.com/1")
+	assert.Equal(t, 1, inner.loadCount)
+
+	now = now.Add(time.Minute)
+	_, _ = client.Load("https://example.com/1")
+	assert.Equal(t, 2, inner.loadCount)
+}
+
+// TestFileCache_Gone confirms a 410 Gone response is remembered, so the document is not fetched again.
+func TestFileCache_Gone(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: goneError{}}
+	client := NewFileCache(inner, t.TempDir())
+
+	_, err := client.Load("https://example.com/1")
+	require.Error(t, err)
+
+	result, err := client.Load("https://example.com/1")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, derp.ErrorCode(err))
+	assert.True(t, result.IsNil())
+	assert.Equal(t, 1, inner.loadCount)
+}
+
+// TestFileCache_Tombstone confirms Tombstones are remembered, even when the server says not to cache them.
+func TestFileCache_Tombstone(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: streams.NewDocument(
+		map[string]any{vocab.PropertyID: "https://example.com/1", vocab.PropertyType: vocab.ObjectTypeTombstone},
+		streams.WithHTTPHeader(http.Header{"Cache-Control": {"no-store"}}),
+	)}
+	client := NewFileCache(inner, t.TempDir())
+
+	_, _ = client.Load("https://example.com/1")
+	result, err := client.Load("https://example.com/1")
+
+	require.NoError(t, err)
+	assert.Equal(t, vocab.ObjectTypeTombstone, result.Type())
+	assert.Equal(t, 1, inner.loadCount)
+}
+
+// TestFileCache_SaveAndDelete confirms Save writes to disk and Delete evicts,
+// and that both pass through to the inner client.
+func TestFileCache_SaveAndDelete(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: errors.New("not found")}
+	client := NewFileCache(inner, t.TempDir())
+
+	require.NoError(t, client.Save(cacheTestDocument("https://example.com/1", http.Header{})))
+	assert.Equal(t, "https://example.com/1", inner.savedDocument.ID())
+
+	_, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, inner.loadCount)
+
+	require.NoError(t, client.Delete("https://example.com/1"))
+	assert.Equal(t, "https://example.com/1", inner.deletedID)
+
+	_, err = client.Load("https://example.com/1")
+	require.Error(t, err)
+	assert.Equal(t, 1, inner.loadCount)
+
+	// Deleting a missing document is not an error
+	require.NoError(t, client.Delete("https://example.com/missing"))
+}
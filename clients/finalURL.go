@@ -0,0 +1,21 @@
+package clients
+
+import (
+	"net/http"
+
+	"github.com/benpate/remote"
+)
+
+// captureFinalURL returns a remote.Option that writes the final URL of a request (which is
+// the original URL unless the request was redirected) into `finalURL`.
+func captureFinalURL(finalURL *string) remote.Option {
+
+	return remote.Option{
+		AfterRequest: func(_ *remote.Transaction, response *http.Response) error {
+			if (response != nil) && (response.Request != nil) && (response.Request.URL != nil) {
+				*finalURL = response.Request.URL.String()
+			}
+			return nil
+		},
+	}
+}
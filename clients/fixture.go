@@ -0,0 +1,213 @@
+package clients
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+)
+
+// Fixture is a streams.Client that loads documents from a directory of JSON files, so that
+// tests can run against realistic fediverse documents without a network connection. Each file
+// is named for the URL it represents (e.g. "https://example.com/users/alice" is stored in
+// "example.com/users/alice.json") and contains the document along with its HTTP headers.
+//
+// Loading a URL that has no fixture is an error (not a "404 Not Found") so that unexpected
+// requests are never mistaken for missing documents. When configured with FixtureRecord,
+// missing fixtures are loaded from a real client and written into the directory instead.
+type Fixture struct {
+	rootClient   streams.Client
+	recordClient streams.Client // If present, missing fixtures are loaded from this client and saved
+
+	directory string           // Directory where fixtures are stored
+	onMissing func(url string) // Called for every URL that has no fixture
+
+	mutex sync.Mutex // Prevents simultaneous recordings from overwriting each other
+}
+
+// fixtureRecord is the on-disk representation of a single fixture
+type fixtureRecord struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Value  any         `json:"value"`
+}
+
+// NewFixture creates a fully initialized Fixture client that reads from the provided directory
+func NewFixture(directory string, options ...FixtureOption) *Fixture {
+
+	result := &Fixture{
+		directory: directory,
+		onMissing: func(string) {},
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load returns the fixture for the provided URL. If there is no fixture, then it
+// is recorded from the real client (if configured) or an error is returned.
+func (client *Fixture) Load(url string, options ...any) (streams.Document, error) {
+
+	const location = "hannibal.clients.Fixture.Load"
+
+	filename := client.Filename(url)
+	data, err := os.ReadFile(filename)
+
+	// Replay the existing fixture
+	if err == nil {
+
+		record := fixtureRecord{}
+
+		if err := json.Unmarshal(data, &record); err != nil {
+			return streams.NilDocument(), derp.Wrap(err, location, "Unable to parse fixture", filename)
+		}
+
+		if record.URL != url {
+			return streams.NilDocument(), derp.Internal(location, "Fixture belongs to a different URL", url, filename, record.URL)
+		}
+
+		return streams.NewDocument(
+			record.Value,
+			streams.WithClient(client.rootClient),
+			streams.WithHTTPHeader(record.Header),
+		), nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return streams.NilDocument(), derp.Wrap(err, location, "Unable to read fixture", filename)
+	}
+
+	// Record a new fixture from the real client
+	if client.recordClient != nil {
+
+		document, err := client.recordClient.Load(url, options...)
+
+		if err != nil {
+			return streams.NilDocument(), derp.Wrap(err, location, "Unable to load document to record", url)
+		}
+
+		if err := client.write(filename, url, document); err != nil {
+			return streams.NilDocument(), derp.Wrap(err, location, "Unable to record fixture", url)
+		}
+
+		document.WithOptions(streams.WithClient(client.rootClient))
+		return document, nil
+	}
+
+	// Otherwise, fail loudly
+	client.onMissing(url)
+	return streams.NilDocument(), derp.Internal(location, "Unexpected URL. No fixture exists for this URL", url, filename)
+}
+
+// Save is required to implement the streams.Client interface.
+// Fixtures are read-only, so Save is a NOOP
+func (client *Fixture) Save(document streams.Document) error {
+	return nil
+}
+
+// Delete is required to implement the streams.Client interface.
+// Fixtures are read-only, so Delete is a NOOP
+func (client *Fixture) Delete(documentID string) error {
+	return nil
+}
+
+// SetRootClient sets the client that is attached to loaded documents,
+// and passes it down to the recording client (if any).
+func (client *Fixture) SetRootClient(rootClient streams.Client) {
+
+	client.rootClient = rootClient
+
+	if client.recordClient != nil {
+		client.recordClient.SetRootClient(rootClient)
+	}
+}
+
+// Filename returns the location on disk of the fixture for the provided URL.
+// The scheme is dropped, and the query string and fragment (if any) are escaped
+// into the file name. URLs without a host are stored by their hash.
+func (client *Fixture) Filename(uri string) string {
+
+	parsed, err := url.Parse(uri)
+
+	if err != nil || parsed.Host == "" {
+		hash := sha256.Sum256([]byte(uri))
+		return filepath.Join(client.directory, hex.EncodeToString(hash[:])+".json")
+	}
+
+	// Colons (in host:port) are not allowed in file names on every platform
+	host := strings.ReplaceAll(strings.ToLower(parsed.Host), ":", "%3A")
+	segments := strings.Split(host+"/"+strings.TrimPrefix(parsed.EscapedPath(), "/"), "/")
+
+	// Dot segments would escape the fixture directory
+	for index, segment := range segments {
+		if segment == "." || segment == ".." {
+			segments[index] = strings.ReplaceAll(segment, ".", "%2E")
+		}
+	}
+
+	// Directory URLs are stored as an "index" file
+	if segments[len(segments)-1] == "" {
+		segments[len(segments)-1] = "index"
+	}
+
+	name := filepath.Join(segments...)
+
+	if parsed.RawQuery != "" {
+		name += "%3F" + url.PathEscape(parsed.RawQuery)
+	}
+
+	if parsed.Fragment != "" {
+		name += "%23" + url.PathEscape(parsed.Fragment)
+	}
+
+	return filepath.Join(client.directory, name+".json")
+}
+
+// write saves a document into the fixture directory
+func (client *Fixture) write(filename string, url string, document streams.Document) error {
+
+	const location = "hannibal.clients.Fixture.write"
+
+	record := fixtureRecord{
+		URL:    url,
+		Header: document.HTTPHeader(),
+		Value:  document.Value(),
+	}
+
+	// Indented JSON is easier to read (and to review) when fixtures are committed
+	data, err := json.MarshalIndent(record, "", "\t")
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to marshal document", url)
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0o750); err != nil {
+		return derp.Wrap(err, location, "Unable to create fixture directory", filename)
+	}
+
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return derp.Wrap(err, location, "Unable to write fixture", filename)
+	}
+
+	return nil
+}
+
+// Verify that Fixture satisfies the streams.Client interface.
+var _ streams.Client = &Fixture{}
@@ -0,0 +1,22 @@
+package clients
+
+import "github.com/benpate/hannibal/streams"
+
+// FixtureOption is a function that modifies a Fixture client
+type FixtureOption func(*Fixture)
+
+// FixtureRecord loads missing fixtures from the provided client (usually a real
+// network client) and writes them into the fixture directory, headers included.
+func FixtureRecord(recordClient streams.Client) FixtureOption {
+	return func(client *Fixture) {
+		client.recordClient = recordClient
+	}
+}
+
+// FixtureOnMissing sets a function that is called for every URL that has no fixture,
+// such as a test's `t.Errorf`, so that unexpected requests fail the test immediately.
+func FixtureOnMissing(onMissing func(url string)) FixtureOption {
+	return func(client *Fixture) {
+		client.onMissing = onMissing
+	}
+}
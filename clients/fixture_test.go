@@ -0,0 +1,109 @@
+package clients
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFixture_Replay confirms documents (and their headers) are loaded from the fixture directory.
+func TestFixture_Replay(t *testing.T) {
+
+	client := NewFixture("testdata/fixtures", FixtureOnMissing(func(url string) {
+		t.Errorf("Unexpected URL: %s", url)
+	}))
+
+	note, err := client.Load("https://mastodon.example/users/alice/statuses/1")
+	require.NoError(t, err)
+	assert.Equal(t, vocab.ObjectTypeNote, note.Type())
+	assert.Equal(t, "application/activity+json; charset=utf-8", note.HTTPHeader().Get("Content-Type"))
+
+	// Linked documents are loaded through the fixture client, too
+	author, err := note.AttributedTo().Load()
+	require.NoError(t, err)
+	assert.Equal(t, "alice", author.PreferredUsername())
+	assert.Equal(t, "https://mastodon.example/users/alice#main-key", author.PublicKey().ID())
+	assert.Same(t, client, author.Client())
+}
+
+// TestFixture_Missing confirms unexpected URLs return an error and call the OnMissing function.
+func TestFixture_Missing(t *testing.T) {
+
+	missing := []string{}
+	client := NewFixture("testdata/fixtures", FixtureOnMissing(func(url string) {
+		missing = append(missing, url)
+	}))
+
+	_, err := client.Load("https://mastodon.example/users/bob")
+	require.Error(t, err)
+	assert.NotEqual(t, http.StatusNotFound, derp.ErrorCode(err))
+	assert.Equal(t, []string{"https://mastodon.example/users/bob"}, missing)
+}
+
+// TestFixture_Record confirms missing fixtures are recorded from the real client, then replayed.
+func TestFixture_Record(t *testing.T) {
+
+	directory := t.TempDir()
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/notes/1?page=true", http.Header{
+		"Content-Type": {vocab.ContentTypeActivityPub},
+	})}
+
+	recorder := NewFixture(directory, FixtureRecord(inner))
+
+	document, err := recorder.Load("https://example.com/notes/1?page=true")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/notes/1?page=true", document.ID())
+	assert.Same(t, recorder, document.Client())
+	assert.Equal(t, 1, inner.loadCount)
+
+	filename := recorder.Filename("https://example.com/notes/1?page=true")
+	assert.FileExists(t, filename)
+
+	// A replay-only client reads the recorded file
+	replay := NewFixture(directory)
+
+	document, err = replay.Load("https://example.com/notes/1?page=true")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/notes/1?page=true", document.ID())
+	assert.Equal(t, vocab.ContentTypeActivityPub, document.HTTPHeader().Get("Content-Type"))
+	assert.Equal(t, 1, inner.loadCount)
+}
+
+// TestFixture_WrongURL confirms a fixture file must match the URL that it is loaded for.
+func TestFixture_WrongURL(t *testing.T) {
+
+	directory := t.TempDir()
+	client := NewFixture(directory)
+
+	filename := client.Filename("https://example.com/1")
+	require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o750))
+	require.NoError(t, os.WriteFile(filename, []byte(`{"url":"https://example.com/2","value":{}}`), 0o600))
+
+	_, err := client.Load("https://example.com/1")
+	require.Error(t, err)
+}
+
+// TestFixture_Filename confirms how URLs are mapped to file names.
+func TestFixture_Filename(t *testing.T) {
+
+	client := NewFixture("fixtures")
+
+	check := func(url string, expected string) {
+		assert.Equal(t, filepath.FromSlash(expected), client.Filename(url), url)
+	}
+
+	check("https://example.com/users/alice", "fixtures/example.com/users/alice.json")
+	check("https://Example.COM/", "fixtures/example.com/index.json")
+	check("https://example.com", "fixtures/example.com/index.json")
+	check("http://localhost:8080/actor", "fixtures/localhost%3A8080/actor.json")
+	check("https://example.com/outbox?page=true", "fixtures/example.com/outbox%3Fpage=true.json")
+	check("https://example.com/users/alice#main-key", "fixtures/example.com/users/alice%23main-key.json")
+	check("https://example.com/a/../../secret", "fixtures/example.com/a/%2E%2E/%2E%2E/secret.json")
+}
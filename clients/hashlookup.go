@@ -14,9 +14,12 @@ type HashLookup struct {
 
 // NewHashLookup creates a fully initialized Client object
 func NewHashLookup(innerClient streams.Client) HashLookup {
-	return HashLookup{
+	result := HashLookup{
 		innerClient: innerClient,
 	}
+
+	result.SetRootClient(result)
+	return result
 }
 
 // Load retrieves a document from the underlying innerClient, then searches for hash values
@@ -16,6 +16,7 @@ import (
 type mockInnerClient struct {
 	loadResult    streams.Document
 	loadErr       error
+	loadCount     int
 	lastLoadURL   string
 	lastLoadOpts  []any
 	savedDocument streams.Document
@@ -24,6 +25,7 @@ type mockInnerClient struct {
 }
 
 func (c *mockInnerClient) Load(url string, options ...any) (streams.Document, error) {
+	c.loadCount++
 	c.lastLoadURL = url
 	c.lastLoadOpts = options
 	return c.loadResult, c.loadErr
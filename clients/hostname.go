@@ -0,0 +1,19 @@
+package clients
+
+import (
+	"net/url"
+	"strings"
+)
+
+// hostnameOf returns the (lowercase) host name of a URL, without its port.
+// It returns an empty string if the URL cannot be parsed.
+func hostnameOf(uri string) string {
+
+	parsed, err := url.Parse(uri)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
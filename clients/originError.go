@@ -0,0 +1,30 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+)
+
+// OriginError is returned by the OriginVerifier client when a remote server returns a
+// document whose ID belongs to a different origin (scheme, host, and port) than the URL
+// that it was loaded from, and the document could not be confirmed from its own ID.
+type OriginError struct {
+	URL string // URL that the document was actually loaded from (after redirects)
+	ID  string // ID that the document claimed to have
+}
+
+// Error implements the error interface
+func (err OriginError) Error() string {
+	return "hannibal.clients.OriginVerifier: document loaded from " + err.URL + " claims a different origin: " + err.ID
+}
+
+// ErrorCode returns HTTP 502 (Bad Gateway) because the remote server returned an invalid response
+func (err OriginError) ErrorCode() int {
+	return http.StatusBadGateway
+}
+
+// IsOriginError returns TRUE if the provided error (or any error that it wraps) is an OriginError
+func IsOriginError(err error) bool {
+	var originError OriginError
+	return errors.As(err, &originError)
+}
@@ -0,0 +1,152 @@
+package clients
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/metadata"
+	"github.com/benpate/hannibal/streams"
+)
+
+// OriginVerifier is a streams.Client wrapper that rejects spoofed documents. A server can return
+// any JSON it likes, including a document whose `id` belongs to some other server. This client
+// confirms that each document's ID has the same origin (scheme, host, and port) as the URL it was
+// actually loaded from, after following redirects.
+//
+// When the origins do not match, the document is either rejected with an OriginError (the default)
+// or loaded again from the ID that it claims, which is accepted only if the origin server returns the
+// same ID. Documents that pass are marked in their Metadata.OriginCheck, so that routers and
+// validators further down the line can trust them.
+//
+// Redirects are detected through a remote.Option passed to the inner client, so this client should
+// wrap a streams.DefaultClient directly. Otherwise, the document is compared to the requested URL.
+type OriginVerifier struct {
+	innerClient streams.Client
+	refetch     bool // If TRUE, mismatched documents are loaded again from their ID instead of being rejected
+}
+
+// NewOriginVerifier creates a fully initialized OriginVerifier client
+func NewOriginVerifier(innerClient streams.Client, options ...OriginVerifierOption) *OriginVerifier {
+
+	result := &OriginVerifier{
+		innerClient: innerClient,
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load retrieves a document from the inner client, and confirms that its ID
+// belongs to the same origin as the URL that it was loaded from.
+func (client *OriginVerifier) Load(url string, options ...any) (streams.Document, error) {
+
+	const location = "hannibal.clients.OriginVerifier.Load"
+
+	document, finalURL, err := client.load(url, options)
+
+	if err != nil {
+		return streams.NilDocument(), derp.Wrap(err, location, "Unable to load document", url)
+	}
+
+	documentID := document.ID()
+
+	// Documents without an ID do not claim to be anything, so there is nothing to verify
+	if documentID == "" {
+		return document, nil
+	}
+
+	if isSameOrigin(documentID, finalURL) {
+		document.Metadata.OriginCheck = metadata.OriginCheckVerified
+		return document, nil
+	}
+
+	if !client.refetch {
+		return streams.NilDocument(), OriginError{URL: finalURL, ID: documentID}
+	}
+
+	// Ask the claimed origin for its own copy of the document
+	refetched, refetchedURL, err := client.load(documentID, options)
+
+	if err != nil {
+		return streams.NilDocument(), derp.Wrap(err, location, "Unable to load document from its claimed ID", url, documentID)
+	}
+
+	if (refetched.ID() != documentID) || !isSameOrigin(documentID, refetchedURL) {
+		return streams.NilDocument(), OriginError{URL: refetchedURL, ID: refetched.ID()}
+	}
+
+	refetched.Metadata.OriginCheck = metadata.OriginCheckRefetched
+	return refetched, nil
+}
+
+// Save passes the document to the inner client.
+func (client *OriginVerifier) Save(document streams.Document) error {
+	return client.innerClient.Save(document)
+}
+
+// Delete passes the document ID to the inner client.
+func (client *OriginVerifier) Delete(documentID string) error {
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient passes the top-level client down to the underlying client.
+func (client *OriginVerifier) SetRootClient(rootClient streams.Client) {
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+// load retrieves a document from the inner client, along with the final URL that
+// it was loaded from (which is the original URL unless the request was redirected)
+func (client *OriginVerifier) load(uri string, options []any) (streams.Document, string, error) {
+
+	finalURL := uri
+
+	options = append(options[:len(options):len(options)], captureFinalURL(&finalURL))
+	document, err := client.innerClient.Load(uri, options...)
+
+	return document, finalURL, err
+}
+
+// isSameOrigin returns TRUE if both URLs have the same scheme, host, and port
+func isSameOrigin(first string, second string) bool {
+
+	firstOrigin, firstOK := origin(first)
+	secondOrigin, secondOK := origin(second)
+
+	return firstOK && secondOK && (firstOrigin == secondOrigin)
+}
+
+// origin returns the normalized "scheme://host:port" of a URL, and
+// FALSE if the URL is not absolute.
+func origin(uri string) (string, bool) {
+
+	parsed, err := url.Parse(uri)
+
+	if err != nil || (parsed.Scheme == "") || (parsed.Host == "") {
+		return "", false
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	port := parsed.Port()
+
+	// Default ports are the same as no port at all
+	if port == "" {
+		switch scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	return scheme + "://" + strings.ToLower(parsed.Hostname()) + ":" + port, true
+}
+
+// Verify that OriginVerifier satisfies the streams.Client interface.
+var _ streams.Client = &OriginVerifier{}
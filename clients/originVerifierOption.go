@@ -0,0 +1,12 @@
+package clients
+
+// OriginVerifierOption is a function that modifies an OriginVerifier client
+type OriginVerifierOption func(*OriginVerifier)
+
+// OriginVerifierRefetch sets whether documents that fail the origin check are loaded again from
+// the ID that they claim (TRUE), or rejected with an OriginError (FALSE, the default).
+func OriginVerifierRefetch(refetch bool) OriginVerifierOption {
+	return func(client *OriginVerifier) {
+		client.refetch = refetch
+	}
+}
@@ -0,0 +1,170 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/metadata"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOriginTestServer returns a test server that responds to every request with the JSON returned by `body`
+func newOriginTestServer(t *testing.T, body func(r *http.Request) string) *httptest.Server {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/activity+json")
+		_, _ = w.Write([]byte(body(r)))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newOriginTestClient returns an OriginVerifier that wraps a DefaultClient that can reach local test servers
+func newOriginTestClient(options ...OriginVerifierOption) *OriginVerifier {
+
+	allowPrivateIPs := remote.Option{
+		BeforeRequest: func(txn *remote.Transaction) error {
+			txn.AllowPrivateIPs(true)
+			return nil
+		},
+	}
+
+	return NewOriginVerifier(streams.NewDefaultClient(allowPrivateIPs), options...)
+}
+
+// TestOriginVerifier_Verified confirms documents from their own origin are marked as verified.
+func TestOriginVerifier_Verified(t *testing.T) {
+
+	var server *httptest.Server
+	server = newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"` + server.URL + `/notes/1","type":"Note"}`
+	})
+
+	document, err := newOriginTestClient().Load(server.URL + "/notes/1")
+	require.NoError(t, err)
+
+	assert.Equal(t, metadata.OriginCheckVerified, document.Metadata.OriginCheck)
+	assert.True(t, document.Metadata.IsOriginVerified())
+}
+
+// TestOriginVerifier_Cached confirms documents served from a Cache are still marked as verified.
+func TestOriginVerifier_Cached(t *testing.T) {
+
+	requests := 0
+
+	var server *httptest.Server
+	server = newOriginTestServer(t, func(r *http.Request) string {
+		requests++
+		return `{"id":"` + server.URL + `/notes/1","type":"Note"}`
+	})
+
+	client := NewCache(newOriginTestClient())
+
+	for range 2 {
+		document, err := client.Load(server.URL + "/notes/1")
+		require.NoError(t, err)
+		assert.True(t, document.Metadata.IsOriginVerified())
+	}
+
+	assert.Equal(t, 1, requests)
+}
+
+// TestOriginVerifier_Redirect confirms the ID is compared to the final URL, after redirects.
+func TestOriginVerifier_Redirect(t *testing.T) {
+
+	var target *httptest.Server
+	target = newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"` + target.URL + `/notes/1","type":"Note"}`
+	})
+
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/notes/1", http.StatusFound)
+	}))
+	t.Cleanup(redirect.Close)
+
+	document, err := newOriginTestClient().Load(redirect.URL + "/notes/1")
+	require.NoError(t, err)
+	assert.Equal(t, metadata.OriginCheckVerified, document.Metadata.OriginCheck)
+}
+
+// TestOriginVerifier_Reject confirms spoofed documents are rejected by default.
+func TestOriginVerifier_Reject(t *testing.T) {
+
+	honest := newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"` + "https://nowhere.example/notes/1" + `","type":"Note"}`
+	})
+
+	spoof := newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"` + honest.URL + `/notes/1","type":"Note","content":"spoofed"}`
+	})
+
+	_, err := newOriginTestClient().Load(spoof.URL + "/notes/1")
+	require.Error(t, err)
+
+	assert.True(t, IsOriginError(err))
+	assert.Equal(t, http.StatusBadGateway, derp.ErrorCode(err))
+}
+
+// TestOriginVerifier_Refetch confirms spoofed documents are replaced by the claimed origin's copy.
+func TestOriginVerifier_Refetch(t *testing.T) {
+
+	var honest *httptest.Server
+	honest = newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"` + honest.URL + `/notes/1","type":"Note","content":"original"}`
+	})
+
+	spoof := newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"` + honest.URL + `/notes/1","type":"Note","content":"spoofed"}`
+	})
+
+	document, err := newOriginTestClient(OriginVerifierRefetch(true)).Load(spoof.URL + "/notes/1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "original", document.Content())
+	assert.Equal(t, metadata.OriginCheckRefetched, document.Metadata.OriginCheck)
+}
+
+// TestOriginVerifier_RefetchMismatch confirms a refetched document must return the claimed ID.
+func TestOriginVerifier_RefetchMismatch(t *testing.T) {
+
+	other := newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"https://nowhere.example/notes/1","type":"Note"}`
+	})
+
+	spoof := newOriginTestServer(t, func(r *http.Request) string {
+		return `{"id":"` + other.URL + `/notes/1","type":"Note"}`
+	})
+
+	_, err := newOriginTestClient(OriginVerifierRefetch(true)).Load(spoof.URL + "/notes/1")
+	assert.True(t, IsOriginError(err))
+}
+
+// TestOriginVerifier_NoID confirms documents without an ID pass through unmarked.
+func TestOriginVerifier_NoID(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: streams.NewDocument(map[string]any{"type": "Note"})}
+
+	document, err := NewOriginVerifier(inner).Load("https://example.com/notes/1")
+	require.NoError(t, err)
+	assert.Equal(t, "", document.Metadata.OriginCheck)
+}
+
+// TestIsSameOrigin confirms which URLs share an origin.
+func TestIsSameOrigin(t *testing.T) {
+
+	assert.True(t, isSameOrigin("https://example.com/1", "https://example.com/2"))
+	assert.True(t, isSameOrigin("https://EXAMPLE.com/1", "HTTPS://example.com:443/2"))
+	assert.True(t, isSameOrigin("https://example.com/users/alice#main-key", "https://example.com/users/alice"))
+
+	assert.False(t, isSameOrigin("https://example.com/1", "http://example.com/1"))
+	assert.False(t, isSameOrigin("https://example.com/1", "https://example.com:8443/1"))
+	assert.False(t, isSameOrigin("https://example.com/1", "https://evil.example.com/1"))
+	assert.False(t, isSameOrigin("/notes/1", "/notes/1"))
+	assert.False(t, isSameOrigin("", ""))
+}
@@ -0,0 +1,51 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// RateLimitError is returned by the RateLimited client when a request was not sent (or was
+// rejected) because of rate limiting. It lets callers tell throttling apart from a real failure
+// to load a document, and to retry after the suggested delay.
+type RateLimitError struct {
+	Host       string        // Host name that is being throttled
+	RetryAfter time.Duration // Suggested time to wait before trying again
+	Err        error         // Original error returned by the remote server, if any
+}
+
+// Error implements the error interface
+func (err RateLimitError) Error() string {
+
+	message := "hannibal.clients.RateLimited: requests to " + err.Host + " are rate limited. Retry after " + err.RetryAfter.String()
+
+	if err.Err != nil {
+		message += ": " + err.Err.Error()
+	}
+
+	return message
+}
+
+// ErrorCode returns HTTP 429 (Too Many Requests) so that derp.ErrorCode reports rate limiting correctly
+func (err RateLimitError) ErrorCode() int {
+	return http.StatusTooManyRequests
+}
+
+// Unwrap returns the original error returned by the remote server, if any
+func (err RateLimitError) Unwrap() error {
+	return err.Err
+}
+
+// IsRateLimitError returns TRUE if the provided error (or any error that it wraps) is a
+// RateLimitError, along with the suggested time to wait before trying again.
+func IsRateLimitError(err error) (bool, time.Duration) {
+
+	var rateLimitError RateLimitError
+
+	if errors.As(err, &rateLimitError) {
+		return true, rateLimitError.RetryAfter
+	}
+
+	return false, 0
+}
@@ -0,0 +1,352 @@
+package clients
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+)
+
+// RateLimited is a streams.Client wrapper that throttles the requests sent to each remote host.
+// It uses a per-host token bucket to limit the request rate, and a per-host semaphore to limit
+// the number of concurrent requests. It also backs off automatically when a remote server
+// responds with "429 Too Many Requests" or reports an exhausted quota in its X-RateLimit-* headers.
+//
+// When a request would have to wait longer than the configured maximum, Load returns a
+// RateLimitError instead of contacting the remote server.
+type RateLimited struct {
+	innerClient streams.Client
+
+	requestsPerSecond float64       // Rate at which each host's token bucket refills
+	burst             int           // Maximum number of tokens in each host's bucket
+	maxConcurrent     int           // Maximum number of simultaneous requests to each host
+	maxWait           time.Duration // Longest time that Load will wait before giving up
+	defaultBackoff    time.Duration // Backoff used when a 429 response does not say how long to wait
+	now               func() time.Time
+	sleep             func(context.Context, time.Duration) error
+
+	mutex     sync.Mutex
+	hosts     map[string]*rateLimitedHost
+	pruneSize int // Size of the map that triggers the next pruning of idle hosts
+}
+
+// rateLimitedHost tracks the throttling state for a single remote host
+type rateLimitedHost struct {
+	tokens       float64
+	lastRefill   time.Time
+	blockedUntil time.Time
+	active       int // Number of requests that have taken a token and not yet finished
+	semaphore    chan struct{}
+}
+
+// NewRateLimited creates a fully initialized RateLimited client
+func NewRateLimited(innerClient streams.Client, options ...RateLimitedOption) *RateLimited {
+
+	result := &RateLimited{
+		innerClient:       innerClient,
+		requestsPerSecond: 2,
+		burst:             10,
+		maxConcurrent:     4,
+		maxWait:           10 * time.Second,
+		defaultBackoff:    time.Minute,
+		now:               time.Now,
+		sleep:             sleepContext,
+		hosts:             make(map[string]*rateLimitedHost),
+		pruneSize:         1024,
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load waits for permission to contact the URL's host, then loads the document from
+// the inner client. It returns a RateLimitError if the host is backing off, or if
+// permission cannot be granted within the maximum wait time. If a context.Context is
+// included in the options, then Load stops waiting when it is cancelled.
+func (client *RateLimited) Load(uri string, options ...any) (streams.Document, error) {
+
+	const location = "hannibal.clients.RateLimited.Load"
+
+	hostname := hostnameOf(uri)
+	ctx := requestContext(options)
+
+	// Wait for a token from this host's bucket
+	host, err := client.waitForToken(ctx, hostname)
+
+	if err != nil {
+		return streams.NilDocument(), err
+	}
+
+	defer client.release(host)
+
+	// Wait for an open slot in this host's semaphore
+	if err := client.acquire(ctx, hostname, host); err != nil {
+
+		// Requests that were never sent give their token back
+		client.refund(host)
+
+		if isRateLimited, _ := IsRateLimitError(err); isRateLimited {
+			return streams.NilDocument(), err
+		}
+
+		return streams.NilDocument(), derp.Wrap(err, location, "Request cancelled while waiting for rate limit", uri)
+	}
+
+	defer func() { <-host.semaphore }()
+
+	// Load the document from the inner client
+	document, err := client.innerClient.Load(uri, options...)
+
+	if err != nil {
+
+		// Back off if the remote server tells us to slow down
+		if tooManyRequests, retryAfter := derp.IsTooManyRequests(err); tooManyRequests {
+			client.backoff(hostname, retryAfter)
+			return document, RateLimitError{Host: hostname, RetryAfter: client.retryAfter(retryAfter), Err: err}
+		}
+
+		return document, err
+	}
+
+	// Back off if the remote server says that our quota is exhausted
+	if resetAfter, exhausted := parseRateLimitHeaders(document.HTTPHeader().Get("X-RateLimit-Remaining"), document.HTTPHeader().Get("X-RateLimit-Reset"), client.now()); exhausted {
+		client.backoff(hostname, resetAfter)
+	}
+
+	return document, nil
+}
+
+// Save passes the document to the inner client.
+func (client *RateLimited) Save(document streams.Document) error {
+	return client.innerClient.Save(document)
+}
+
+// Delete passes the document ID to the inner client.
+func (client *RateLimited) Delete(documentID string) error {
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient passes the top-level client down to the underlying client.
+func (client *RateLimited) SetRootClient(rootClient streams.Client) {
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+/******************************************
+ * Internal Methods
+ ******************************************/
+
+// hostLocked returns the throttling state for a hostname.  The caller must hold the mutex.
+func (client *RateLimited) hostLocked(hostname string) *rateLimitedHost {
+
+	if host, ok := client.hosts[hostname]; ok {
+		return host
+	}
+
+	// Remove idle hosts whenever the map doubles in size
+	if len(client.hosts) >= client.pruneSize {
+		client.pruneLocked()
+	}
+
+	host := &rateLimitedHost{
+		tokens:     float64(client.burst),
+		lastRefill: client.now(),
+		semaphore:  make(chan struct{}, client.maxConcurrent),
+	}
+
+	client.hosts[hostname] = host
+	return host
+}
+
+// pruneLocked removes hosts that have no requests in progress, are not backing off, and
+// whose buckets have refilled, because they behave exactly like new hosts. The caller
+// must hold the mutex.
+func (client *RateLimited) pruneLocked() {
+
+	now := client.now()
+
+	for hostname, host := range client.hosts {
+
+		if host.active > 0 || now.Before(host.blockedUntil) {
+			continue
+		}
+
+		if host.tokens+(now.Sub(host.lastRefill).Seconds()*client.requestsPerSecond) < float64(client.burst) {
+			continue
+		}
+
+		delete(client.hosts, hostname)
+	}
+
+	client.pruneSize = max(1024, len(client.hosts)*2)
+}
+
+// waitForToken takes a token from the host's bucket, sleeping until one is available, and
+// returns the host's throttling state. Callers must release the host when they are done.
+// If the host is backing off, or the wait would be too long, it returns a RateLimitError
+// without taking a token. If the context is cancelled while waiting, the token is returned
+// to the bucket.
+func (client *RateLimited) waitForToken(ctx context.Context, hostname string) (*rateLimitedHost, error) {
+
+	const location = "hannibal.clients.RateLimited.waitForToken"
+
+	client.mutex.Lock()
+
+	host := client.hostLocked(hostname)
+	now := client.now()
+
+	// RULE: Do not contact hosts that have asked us to back off
+	if now.Before(host.blockedUntil) {
+		client.mutex.Unlock()
+		return nil, RateLimitError{Host: hostname, RetryAfter: host.blockedUntil.Sub(now)}
+	}
+
+	// Refill the bucket based on the time since the last request
+	elapsed := now.Sub(host.lastRefill).Seconds()
+	host.tokens = min(float64(client.burst), host.tokens+(elapsed*client.requestsPerSecond))
+	host.lastRefill = now
+
+	// Calculate how long we must wait for the next token
+	wait := time.Duration(0)
+
+	if host.tokens < 1 {
+		wait = time.Duration((1 - host.tokens) / client.requestsPerSecond * float64(time.Second))
+	}
+
+	if wait > client.maxWait {
+		client.mutex.Unlock()
+		return nil, RateLimitError{Host: hostname, RetryAfter: wait}
+	}
+
+	// Reserve the token now, so that concurrent callers queue up behind us
+	host.tokens--
+	host.active++
+	client.mutex.Unlock()
+
+	if wait <= 0 {
+		return host, nil
+	}
+
+	if err := client.sleep(ctx, wait); err != nil {
+		client.refund(host)
+		client.release(host)
+		return nil, derp.Wrap(err, location, "Request cancelled while waiting for rate limit", hostname)
+	}
+
+	return host, nil
+}
+
+// refund returns a token to the host's bucket, for a request that was never sent
+func (client *RateLimited) refund(host *rateLimitedHost) {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	host.tokens = min(float64(client.burst), host.tokens+1)
+}
+
+// release marks a request to the host as finished, so that an idle host can be pruned
+func (client *RateLimited) release(host *rateLimitedHost) {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	host.active--
+}
+
+// acquire takes a slot in the host's semaphore, waiting up to maxWait for one to open.
+// It returns a RateLimitError if no slot opened in time, or the context's error if it
+// was cancelled first.
+func (client *RateLimited) acquire(ctx context.Context, hostname string, host *rateLimitedHost) error {
+
+	// Take an open slot immediately, if one exists
+	select {
+	case host.semaphore <- struct{}{}:
+		return nil
+	default:
+	}
+
+	// Otherwise, wait for a slot to open
+	timer := time.NewTimer(client.maxWait)
+	defer timer.Stop()
+
+	select {
+	case host.semaphore <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return RateLimitError{Host: hostname, RetryAfter: client.maxWait}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff blocks all requests to the host for the provided duration
+func (client *RateLimited) backoff(hostname string, duration time.Duration) {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	host := client.hostLocked(hostname)
+	host.blockedUntil = client.now().Add(client.retryAfter(duration))
+}
+
+// retryAfter returns the provided duration, or the default backoff if it is empty
+func (client *RateLimited) retryAfter(duration time.Duration) time.Duration {
+
+	if duration <= 0 {
+		return client.defaultBackoff
+	}
+
+	return duration
+}
+
+// parseRateLimitHeaders reads the X-RateLimit-Remaining and X-RateLimit-Reset headers. It returns
+// TRUE if the quota is exhausted, along with the time remaining until the quota resets (zero if unknown).
+// X-RateLimit-Reset may be a timestamp (Mastodon), a Unix epoch, or a number of seconds.
+func parseRateLimitHeaders(remaining string, reset string, now time.Time) (time.Duration, bool) {
+
+	// RULE: Only back off when the server says that we have no requests left
+	if count, err := strconv.ParseFloat(strings.TrimSpace(remaining), 64); err != nil || count > 0 {
+		return 0, false
+	}
+
+	reset = strings.TrimSpace(reset)
+
+	resetAfter := time.Duration(0)
+
+	if resetTime, err := time.Parse(time.RFC3339, reset); err == nil {
+		resetAfter = resetTime.Sub(now)
+
+	} else if seconds, err := strconv.ParseFloat(reset, 64); err == nil {
+
+		// Large values are Unix epochs. Small values are a number of seconds.
+		if seconds > 1_000_000_000 {
+			resetAfter = time.Unix(int64(seconds), 0).Sub(now)
+		} else {
+			resetAfter = time.Duration(seconds * float64(time.Second))
+		}
+
+	} else {
+		// The reset time is unknown, so the caller should use its default backoff
+		return 0, true
+	}
+
+	// RULE: A quota that has already reset is not exhausted
+	if resetAfter <= 0 {
+		return 0, false
+	}
+
+	return resetAfter, true
+}
+
+// Verify that RateLimited satisfies the streams.Client interface.
+var _ streams.Client = &RateLimited{}
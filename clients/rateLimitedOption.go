@@ -0,0 +1,45 @@
+package clients
+
+import "time"
+
+// RateLimitedOption is a function that modifies a RateLimited client
+type RateLimitedOption func(*RateLimited)
+
+// RateLimitRequests sets the sustained number of requests per second allowed for each host,
+// along with the number of requests that can be sent in a single burst. Invalid values are ignored.
+func RateLimitRequests(requestsPerSecond float64, burst int) RateLimitedOption {
+	return func(client *RateLimited) {
+		if requestsPerSecond > 0 {
+			client.requestsPerSecond = requestsPerSecond
+		}
+		if burst > 0 {
+			client.burst = burst
+		}
+	}
+}
+
+// RateLimitConcurrency sets the maximum number of simultaneous requests to each host.
+// Values less than one are ignored.
+func RateLimitConcurrency(maxConcurrent int) RateLimitedOption {
+	return func(client *RateLimited) {
+		if maxConcurrent > 0 {
+			client.maxConcurrent = maxConcurrent
+		}
+	}
+}
+
+// RateLimitMaxWait sets the longest time that Load will wait for permission to contact a host
+// before returning a RateLimitError. Zero means that Load never waits.
+func RateLimitMaxWait(maxWait time.Duration) RateLimitedOption {
+	return func(client *RateLimited) {
+		client.maxWait = maxWait
+	}
+}
+
+// RateLimitDefaultBackoff sets how long to back off from a host that responds with
+// "429 Too Many Requests" but does not say how long to wait.
+func RateLimitDefaultBackoff(backoff time.Duration) RateLimitedOption {
+	return func(client *RateLimited) {
+		client.defaultBackoff = backoff
+	}
+}
@@ -0,0 +1,299 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tooManyRequestsError is an error that reports HTTP 429 to derp.ErrorCode
+type tooManyRequestsError struct{}
+
+func (tooManyRequestsError) Error() string  { return "too many requests" }
+func (tooManyRequestsError) ErrorCode() int { return http.StatusTooManyRequests }
+
+// newTestRateLimited returns a RateLimited client whose clock only moves when it sleeps
+func newTestRateLimited(inner streams.Client, options ...RateLimitedOption) (*RateLimited, *time.Time, *[]time.Duration) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sleeps := []time.Duration{}
+
+	client := NewRateLimited(inner, options...)
+	client.now = func() time.Time { return now }
+	client.sleep = func(ctx context.Context, duration time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sleeps = append(sleeps, duration)
+		now = now.Add(duration)
+		return nil
+	}
+
+	return client, &now, &sleeps
+}
+
+// TestRateLimited_TokenBucket confirms requests beyond the burst wait for the bucket to refill.
+func TestRateLimited_TokenBucket(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client, _, sleeps := newTestRateLimited(inner, RateLimitRequests(1, 2))
+
+	for range 3 {
+		_, err := client.Load("https://example.com/1")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, inner.loadCount)
+	assert.Equal(t, []time.Duration{time.Second}, *sleeps)
+}
+
+// TestRateLimited_MaxWait confirms a RateLimitError is returned when the wait would be too long.
+func TestRateLimited_MaxWait(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client, _, sleeps := newTestRateLimited(inner, RateLimitRequests(1, 1), RateLimitMaxWait(0))
+
+	_, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+
+	_, err = client.Load("https://example.com/1")
+	require.Error(t, err)
+
+	isRateLimited, retryAfter := IsRateLimitError(err)
+	assert.True(t, isRateLimited)
+	assert.Equal(t, time.Second, retryAfter)
+	assert.Equal(t, http.StatusTooManyRequests, derp.ErrorCode(err))
+	assert.Equal(t, 1, inner.loadCount)
+	assert.Empty(t, *sleeps)
+
+	// Other hosts have their own bucket
+	_, err = client.Load("https://other.example/1")
+	require.NoError(t, err)
+}
+
+// TestRateLimited_TooManyRequests confirms a 429 response makes the client back off from that host.
+func TestRateLimited_TooManyRequests(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: tooManyRequestsError{}}
+	client, now, _ := newTestRateLimited(inner, RateLimitMaxWait(0))
+
+	_, err := client.Load("https://example.com/1")
+	isRateLimited, _ := IsRateLimitError(err)
+	assert.True(t, isRateLimited)
+	assert.ErrorIs(t, err, tooManyRequestsError{})
+
+	// Backing off: the inner client is not called again
+	_, err = client.Load("https://example.com/2")
+	isRateLimited, retryAfter := IsRateLimitError(err)
+	assert.True(t, isRateLimited)
+	assert.Greater(t, retryAfter, time.Duration(0))
+	assert.Equal(t, 1, inner.loadCount)
+
+	// After the backoff, requests resume
+	*now = now.Add(retryAfter)
+	inner.loadErr = nil
+	inner.loadResult = cacheTestDocument("https://example.com/2", http.Header{})
+
+	_, err = client.Load("https://example.com/2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.loadCount)
+}
+
+// TestRateLimited_QuotaHeaders confirms an exhausted X-RateLimit quota makes the client back off.
+func TestRateLimited_QuotaHeaders(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {"2024-01-01T00:05:00.000Z"},
+	})}
+	client, _, _ := newTestRateLimited(inner, RateLimitMaxWait(0))
+
+	_, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+
+	_, err = client.Load("https://example.com/1")
+	isRateLimited, retryAfter := IsRateLimitError(err)
+	assert.True(t, isRateLimited)
+	assert.Equal(t, 5*time.Minute, retryAfter)
+	assert.Equal(t, 1, inner.loadCount)
+}
+
+// blockingClient is a streams.Client whose Load blocks until it is released
+type blockingClient struct {
+	mockInnerClient
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingClient) Load(url string, options ...any) (streams.Document, error) {
+	c.started <- struct{}{}
+	<-c.release
+	return streams.NilDocument(), nil
+}
+
+// TestRateLimited_Concurrency confirms the per-host limit on simultaneous requests.
+func TestRateLimited_Concurrency(t *testing.T) {
+
+	inner := &blockingClient{started: make(chan struct{}), release: make(chan struct{})}
+	client := NewRateLimited(inner, RateLimitConcurrency(1), RateLimitMaxWait(0))
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		_, err := client.Load("https://example.com/1")
+		assert.NoError(t, err)
+	})
+
+	<-inner.started
+
+	// The only slot is taken, so this request is refused
+	_, err := client.Load("https://example.com/2")
+	isRateLimited, _ := IsRateLimitError(err)
+	assert.True(t, isRateLimited)
+
+	close(inner.release)
+	wg.Wait()
+}
+
+// TestRateLimited_RefundWhenRefused confirms requests that give up waiting for a slot
+// return their token to the bucket.
+func TestRateLimited_RefundWhenRefused(t *testing.T) {
+
+	inner := &blockingClient{started: make(chan struct{}), release: make(chan struct{})}
+	client, _, _ := newTestRateLimited(inner, RateLimitRequests(1, 2), RateLimitConcurrency(1), RateLimitMaxWait(0))
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		_, err := client.Load("https://example.com/1")
+		assert.NoError(t, err)
+	})
+
+	<-inner.started
+
+	_, err := client.Load("https://example.com/2")
+	isRateLimited, _ := IsRateLimitError(err)
+	assert.True(t, isRateLimited)
+
+	client.mutex.Lock()
+	assert.Equal(t, float64(1), client.hosts["example.com"].tokens, "refused requests must not use up a token")
+	client.mutex.Unlock()
+
+	close(inner.release)
+	wg.Wait()
+}
+
+// TestRateLimited_PruneIdleHosts confirms idle hosts are removed as the map grows, while hosts
+// that are backing off or have not yet refilled are kept.
+func TestRateLimited_PruneIdleHosts(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+	client, now, _ := newTestRateLimited(inner, RateLimitRequests(1, 10), RateLimitMaxWait(0))
+	client.pruneSize = 4
+
+	_, err := client.Load("https://one.example/1")
+	require.NoError(t, err)
+	client.backoff("one.example", time.Hour)
+
+	_, err = client.Load("https://two.example/1")
+	require.NoError(t, err)
+
+	// Both buckets refill, but one.example is still backing off
+	*now = now.Add(time.Minute)
+
+	_, err = client.Load("https://three.example/1")
+	require.NoError(t, err)
+
+	_, err = client.Load("https://four.example/1")
+	require.NoError(t, err)
+
+	_, err = client.Load("https://five.example/1")
+	require.NoError(t, err)
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	assert.Contains(t, client.hosts, "one.example")
+	assert.NotContains(t, client.hosts, "two.example")
+	assert.Contains(t, client.hosts, "three.example", "hosts that have not refilled must be kept")
+	assert.Contains(t, client.hosts, "five.example")
+	assert.Equal(t, 1024, client.pruneSize)
+}
+
+// TestRateLimited_CancelledWhileWaiting confirms a cancelled context stops the wait for a token
+// (returning the token to the bucket) and the wait for an open slot.
+func TestRateLimited_CancelledWhileWaiting(t *testing.T) {
+
+	t.Run("token", func(t *testing.T) {
+
+		inner := &mockInnerClient{loadResult: cacheTestDocument("https://example.com/1", http.Header{})}
+		client, _, sleeps := newTestRateLimited(inner, RateLimitRequests(1, 1))
+
+		_, err := client.Load("https://example.com/1")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = client.Load("https://example.com/1", ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, inner.loadCount)
+		assert.Empty(t, *sleeps)
+
+		// The token was returned, so the next request waits the same amount of time
+		_, err = client.Load("https://example.com/1")
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{time.Second}, *sleeps)
+	})
+
+	t.Run("slot", func(t *testing.T) {
+
+		inner := &blockingClient{started: make(chan struct{}), release: make(chan struct{})}
+		client := NewRateLimited(inner, RateLimitConcurrency(1), RateLimitMaxWait(time.Hour))
+
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			_, err := client.Load("https://example.com/1")
+			assert.NoError(t, err)
+		})
+
+		<-inner.started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Load("https://example.com/2", ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		isRateLimited, _ := IsRateLimitError(err)
+		assert.False(t, isRateLimited)
+
+		close(inner.release)
+		wg.Wait()
+	})
+}
+
+// TestParseRateLimitHeaders confirms each supported format of the X-RateLimit-* headers.
+func TestParseRateLimitHeaders(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	check := func(description string, remaining string, reset string, expectedDuration time.Duration, expectedExhausted bool) {
+		duration, exhausted := parseRateLimitHeaders(remaining, reset, now)
+		assert.Equal(t, expectedDuration, duration, description)
+		assert.Equal(t, expectedExhausted, exhausted, description)
+	}
+
+	check("no headers", "", "", 0, false)
+	check("quota remaining", "10", "60", 0, false)
+	check("timestamp", "0", "2024-01-01T00:01:00Z", time.Minute, true)
+	check("epoch", "0", "1704067230", 30*time.Second, true)
+	check("seconds", "0", "45", 45*time.Second, true)
+	check("unknown reset", "0", "soon", 0, true)
+	check("already reset", "0", "2023-12-31T23:59:00Z", 0, false)
+}
@@ -0,0 +1,54 @@
+package clients
+
+import (
+	"context"
+	"time"
+)
+
+// requestContext returns the context.Context included in a list of Load options, or
+// context.Background() if there is none. When several are included, the last one wins,
+// which matches the order in which streams.DefaultClient applies them.
+func requestContext(options []any) context.Context {
+
+	result := context.Background()
+
+	for _, option := range options {
+		if ctx, ok := option.(context.Context); ok {
+			result = ctx
+		}
+	}
+
+	return result
+}
+
+// withoutCancel returns a copy of the Load options in which every context.Context keeps
+// its values, but is no longer cancelled along with its parent.
+func withoutCancel(options []any) []any {
+
+	result := make([]any, len(options))
+
+	for index, option := range options {
+		if ctx, ok := option.(context.Context); ok {
+			option = context.WithoutCancel(ctx)
+		}
+
+		result[index] = option
+	}
+
+	return result
+}
+
+// sleepContext waits for the provided duration, or until the context is cancelled,
+// whichever comes first. It returns the context's error if the wait was cut short.
+func sleepContext(ctx context.Context, duration time.Duration) error {
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,211 @@
+package clients
+
+import (
+	"container/list"
+	"crypto"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/sigs"
+	"github.com/benpate/hannibal/streams"
+)
+
+// SignedFetch is a streams.Client wrapper that signs outbound GET requests with an
+// HTTP Signature, so that documents can be loaded from servers running in "authorized
+// fetch" (secure) mode, which reject unsigned requests.
+//
+// Signatures cover only the "(request-target) host date" fields, and are attached to each
+// request as a remote.Option, so the inner client must honor remote.Options passed to Load
+// (as streams.DefaultClient does).
+//
+// SignedFetch learns which hosts require signatures. When a request is refused with
+// "401 Unauthorized" or "403 Forbidden", it is retried the other way (signed instead of
+// unsigned, or vice versa), and whichever way succeeds is used for that host from then on.
+// Only hosts that differ from the default are remembered, in a bounded LRU.
+type SignedFetch struct {
+	innerClient streams.Client
+	rootClient  streams.Client
+	signer      sigs.Signer
+
+	signByDefault bool // If TRUE, requests to unfamiliar hosts are signed first
+	maxHosts      int  // Maximum number of learned policies to remember
+
+	mutex    sync.Mutex
+	hosts    map[string]bool          // Map of configured host names to whether requests to that host are signed
+	policies map[string]*list.Element // Map of learned host names to their element in the LRU
+	order    *list.List               // Learned policies, from most to least recently used
+}
+
+// signedFetchPolicy is a single learned policy in a SignedFetch client's LRU
+type signedFetchPolicy struct {
+	hostname string
+	signed   bool
+}
+
+// NewSignedFetch creates a fully initialized SignedFetch client that signs requests
+// with the provided key (typically belonging to the server's instance actor).
+func NewSignedFetch(innerClient streams.Client, publicKeyID string, privateKey crypto.PrivateKey, options ...SignedFetchOption) *SignedFetch {
+
+	result := &SignedFetch{
+		innerClient: innerClient,
+		signer: sigs.NewSigner(publicKeyID, privateKey,
+			sigs.SignerFields(sigs.FieldRequestTarget, sigs.FieldHost, sigs.FieldDate),
+		),
+		signByDefault: true,
+		maxHosts:      10_000,
+		hosts:         make(map[string]bool),
+		policies:      make(map[string]*list.Element),
+		order:         list.New(),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load retrieves a document from the inner client, signing the request if the URL's host
+// requires (or may require) it. If the remote server refuses the request, it is retried
+// once with the opposite policy.
+func (client *SignedFetch) Load(url string, options ...any) (streams.Document, error) {
+
+	const location = "hannibal.clients.SignedFetch.Load"
+
+	hostname := hostnameOf(url)
+	signed := client.policy(hostname)
+
+	// Try to load the document using the policy that we expect to work
+	document, err := client.load(url, signed, options)
+
+	if err == nil {
+		client.setPolicy(hostname, signed)
+		return document, nil
+	}
+
+	if !isAuthorizationError(err) {
+		return streams.NilDocument(), derp.Wrap(err, location, "Unable to load document", url)
+	}
+
+	// Remote server refused the request, so try again with the opposite policy
+	document, err = client.load(url, !signed, options)
+
+	if err != nil {
+		return streams.NilDocument(), derp.Wrap(err, location, "Unable to load document, signed or unsigned", url)
+	}
+
+	client.setPolicy(hostname, !signed)
+	return document, nil
+}
+
+// Save passes the document to the inner client.
+func (client *SignedFetch) Save(document streams.Document) error {
+	return client.innerClient.Save(document)
+}
+
+// Delete passes the document ID to the inner client.
+func (client *SignedFetch) Delete(documentID string) error {
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient sets the client that is attached to loaded documents (so that linked
+// documents are also loaded with signatures), and passes it down to the underlying client.
+func (client *SignedFetch) SetRootClient(rootClient streams.Client) {
+
+	client.rootClient = rootClient
+
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+// load retrieves a document from the inner client, adding a signature if requested.
+func (client *SignedFetch) load(url string, signed bool, options []any) (streams.Document, error) {
+
+	if signed {
+		options = append(slices.Clone(options), sigs.WithSigner(client.signer))
+	}
+
+	document, err := client.innerClient.Load(url, options...)
+
+	if err != nil {
+		return document, err
+	}
+
+	document.WithOptions(streams.WithClient(client.rootClient))
+	return document, nil
+}
+
+// policy returns TRUE if requests to the provided host should be signed
+func (client *SignedFetch) policy(hostname string) bool {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if signed, ok := client.hosts[hostname]; ok {
+		return signed
+	}
+
+	if element, ok := client.policies[hostname]; ok {
+		client.order.MoveToFront(element)
+		return element.Value.(*signedFetchPolicy).signed
+	}
+
+	return client.signByDefault
+}
+
+// setPolicy records whether requests to the provided host should be signed. Configured
+// hosts are updated in place. Learned policies that match the default are forgotten, and
+// the least recently used policies are removed when there are too many.
+func (client *SignedFetch) setPolicy(hostname string, signed bool) {
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if _, ok := client.hosts[hostname]; ok {
+		client.hosts[hostname] = signed
+		return
+	}
+
+	element, ok := client.policies[hostname]
+
+	if signed == client.signByDefault {
+		if ok {
+			client.order.Remove(element)
+			delete(client.policies, hostname)
+		}
+		return
+	}
+
+	if ok {
+		element.Value.(*signedFetchPolicy).signed = signed
+		client.order.MoveToFront(element)
+		return
+	}
+
+	client.policies[hostname] = client.order.PushFront(&signedFetchPolicy{hostname: hostname, signed: signed})
+
+	for client.order.Len() > client.maxHosts {
+		oldest := client.order.Back()
+		client.order.Remove(oldest)
+		delete(client.policies, oldest.Value.(*signedFetchPolicy).hostname)
+	}
+}
+
+// isAuthorizationError returns TRUE if the remote server refused a request
+// because it was (or was not) signed.
+func isAuthorizationError(err error) bool {
+
+	switch derp.ErrorCode(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+
+	return false
+}
+
+// Verify that SignedFetch satisfies the streams.Client interface.
+var _ streams.Client = &SignedFetch{}
@@ -0,0 +1,31 @@
+package clients
+
+// SignedFetchOption is a function that modifies a SignedFetch client
+type SignedFetchOption func(*SignedFetch)
+
+// SignedFetchSignByDefault sets whether the first request to an unfamiliar host is signed.
+// Signing by default (the default) works with secure-mode servers on the first try, while
+// not signing avoids sending signatures to servers that don't need them.
+func SignedFetchSignByDefault(signByDefault bool) SignedFetchOption {
+	return func(client *SignedFetch) {
+		client.signByDefault = signByDefault
+	}
+}
+
+// SignedFetchHost records whether requests to a specific host should be signed, so that
+// known hosts never need a retry. Policies learned from later responses replace this value.
+func SignedFetchHost(hostname string, signed bool) SignedFetchOption {
+	return func(client *SignedFetch) {
+		client.hosts[hostnameOf("https://"+hostname)] = signed
+	}
+}
+
+// SignedFetchMaxHosts sets how many learned per-host policies are remembered (default 10,000).
+// When there are more, the least recently used are forgotten. Values less than one are ignored.
+func SignedFetchMaxHosts(maxHosts int) SignedFetchOption {
+	return func(client *SignedFetch) {
+		if maxHosts > 0 {
+			client.maxHosts = maxHosts
+		}
+	}
+}
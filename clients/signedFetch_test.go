@@ -0,0 +1,196 @@
+package clients
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benpate/hannibal/sigs"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedFetchServer is a test server that accepts or refuses signed requests
+type signedFetchServer struct {
+	*httptest.Server
+	requireSignature bool     // If TRUE, unsigned requests are refused with 401
+	refuseSignature  bool     // If TRUE, signed requests are refused with 403
+	requests         []string // "signed" or "unsigned" for each request received
+}
+
+func newSignedFetchServer(t *testing.T, privateKey *rsa.PrivateKey) *signedFetchServer {
+
+	result := &signedFetchServer{}
+
+	result.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Signature") == "" {
+			result.requests = append(result.requests, "unsigned")
+
+			if result.requireSignature {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+		} else {
+			result.requests = append(result.requests, "signed")
+
+			if result.refuseSignature {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			// Signature must be valid, and cover only the minimum fields
+			signature, err := sigs.Verify(r, func(keyID string) (string, error) {
+				return sigs.EncodePublicPEM(privateKey), nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "https://local.example/@instance#main-key", signature.KeyID)
+			assert.Equal(t, "(request-target) host date", strings.Join(signature.Headers, " "))
+		}
+
+		w.Header().Set("Content-Type", vocab.ContentTypeActivityPub)
+		_, _ = w.Write([]byte(`{"id":"https://remote.example/1","type":"Note"}`))
+	}))
+
+	t.Cleanup(result.Close)
+	return result
+}
+
+// newTestSignedFetch returns a SignedFetch client (that can reach the local test server), and its key
+func newTestSignedFetch(t *testing.T, options ...SignedFetchOption) (*SignedFetch, *rsa.PrivateKey) {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	allowPrivateIPs := remote.Option{
+		BeforeRequest: func(txn *remote.Transaction) error {
+			txn.AllowPrivateIPs(true)
+			return nil
+		},
+	}
+
+	inner := streams.NewDefaultClient(allowPrivateIPs)
+	return NewSignedFetch(inner, "https://local.example/@instance#main-key", privateKey, options...), privateKey
+}
+
+// TestSignedFetch_Signed confirms requests are signed by default, and that secure-mode servers accept them.
+func TestSignedFetch_Signed(t *testing.T) {
+
+	client, privateKey := newTestSignedFetch(t)
+	server := newSignedFetchServer(t, privateKey)
+	server.requireSignature = true
+
+	document, err := client.Load(server.URL)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://remote.example/1", document.ID())
+	assert.Same(t, client, document.Client())
+	assert.Equal(t, []string{"signed"}, server.requests)
+}
+
+// TestSignedFetch_LearnSigned confirms a refused unsigned request is retried with a
+// signature, and that later requests to the same host are signed right away.
+func TestSignedFetch_LearnSigned(t *testing.T) {
+
+	client, privateKey := newTestSignedFetch(t, SignedFetchSignByDefault(false))
+	server := newSignedFetchServer(t, privateKey)
+	server.requireSignature = true
+
+	_, err := client.Load(server.URL)
+	require.NoError(t, err)
+
+	_, err = client.Load(server.URL)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"unsigned", "signed", "signed"}, server.requests)
+}
+
+// TestSignedFetch_LearnUnsigned confirms a refused signed request is retried without a
+// signature, and that later requests to the same host are not signed.
+func TestSignedFetch_LearnUnsigned(t *testing.T) {
+
+	client, privateKey := newTestSignedFetch(t)
+	server := newSignedFetchServer(t, privateKey)
+	server.refuseSignature = true
+
+	_, err := client.Load(server.URL)
+	require.NoError(t, err)
+
+	_, err = client.Load(server.URL)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"signed", "unsigned", "unsigned"}, server.requests)
+}
+
+// TestSignedFetch_Refused confirms an error is returned when neither kind of request is accepted.
+func TestSignedFetch_Refused(t *testing.T) {
+
+	client, privateKey := newTestSignedFetch(t)
+	server := newSignedFetchServer(t, privateKey)
+	server.requireSignature = true
+	server.refuseSignature = true
+
+	_, err := client.Load(server.URL)
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"signed", "unsigned"}, server.requests)
+}
+
+// TestSignedFetch_OtherErrors confirms errors other than 401/403 are not retried.
+func TestSignedFetch_OtherErrors(t *testing.T) {
+
+	inner := &mockInnerClient{loadErr: goneError{}}
+	client := NewSignedFetch(inner, "https://local.example/@instance#main-key", nil)
+
+	_, err := client.Load("https://remote.example/1")
+	require.Error(t, err)
+	assert.Equal(t, 1, inner.loadCount)
+}
+
+// TestSignedFetch_Host confirms the per-host policy option.
+func TestSignedFetch_Host(t *testing.T) {
+
+	inner := &mockInnerClient{loadResult: cacheTestDocument("https://remote.example/1", http.Header{})}
+	client := NewSignedFetch(inner, "https://local.example/@instance#main-key", nil, SignedFetchHost("Remote.Example", false))
+
+	_, err := client.Load("https://remote.example/1")
+	require.NoError(t, err)
+
+	// No signer was added to the options for this host
+	assert.Empty(t, inner.lastLoadOpts)
+}
+
+// TestSignedFetch_MaxHosts confirms only policies that differ from the default are remembered,
+// that the least recently used are forgotten first, and that configured hosts are kept.
+func TestSignedFetch_MaxHosts(t *testing.T) {
+
+	client := NewSignedFetch(&mockInnerClient{}, "https://local.example/@instance#main-key", nil,
+		SignedFetchMaxHosts(2),
+		SignedFetchHost("configured.example", false),
+	)
+
+	client.setPolicy("default.example", true)
+	client.setPolicy("one.example", false)
+	client.setPolicy("two.example", false)
+
+	// one.example is used again, so two.example is now the oldest
+	assert.False(t, client.policy("one.example"))
+
+	client.setPolicy("three.example", false)
+	client.setPolicy("configured.example", true)
+
+	assert.Equal(t, 2, client.order.Len())
+	assert.NotContains(t, client.policies, "default.example")
+	assert.NotContains(t, client.policies, "two.example")
+	assert.True(t, client.policy("two.example"))
+	assert.False(t, client.policy("one.example"))
+	assert.False(t, client.policy("three.example"))
+	assert.True(t, client.policy("configured.example"))
+}
@@ -0,0 +1,130 @@
+package clients
+
+import (
+	"sync"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+)
+
+// Singleflight is a streams.Client wrapper that merges concurrent Load calls for the same URL
+// into a single request to the inner client. Every caller receives its own copy of the same
+// result, so a popular document that fans out to many goroutines is only fetched once.
+//
+// Requests are merged only while they are in flight; nothing is cached afterward. Stack a
+// Cache in front of (or behind) this client to keep documents for longer.
+type Singleflight struct {
+	innerClient streams.Client
+
+	mutex sync.Mutex
+	calls map[string]*singleflightCall
+}
+
+// singleflightCall is a Load call that is in progress (or has just completed)
+type singleflightCall struct {
+	done     chan struct{} // Closed when the call has completed
+	document streams.Document
+	err      error
+}
+
+// NewSingleflight creates a fully initialized Singleflight client
+func NewSingleflight(innerClient streams.Client) *Singleflight {
+	result := &Singleflight{
+		innerClient: innerClient,
+		calls:       make(map[string]*singleflightCall),
+	}
+
+	result.SetRootClient(result)
+	return result
+}
+
+// Load returns the document at the provided URL. If another goroutine is already loading
+// the same URL, this call waits for that result instead of sending a second request.
+//
+// The shared request runs on its own, using the options (including any context.Context)
+// from whichever caller started it. Its context keeps its values but is never cancelled,
+// so one caller giving up does not fail the request for everyone else. Instead, each
+// caller stops waiting (and returns an error) when its own context is cancelled.
+//
+// Callers that need different options for the same URL should not share a Singleflight.
+// The inner client must not Load the same URL recursively through this client, because
+// that call would wait on itself.
+func (client *Singleflight) Load(url string, options ...any) (streams.Document, error) {
+
+	const location = "hannibal.clients.Singleflight.Load"
+
+	client.mutex.Lock()
+
+	// If the URL is not already in flight, then start a request on behalf of everyone
+	call, ok := client.calls[url]
+
+	if !ok {
+		call = &singleflightCall{done: make(chan struct{})}
+		client.calls[url] = call
+		go client.fetch(url, call, withoutCancel(options))
+	}
+
+	client.mutex.Unlock()
+
+	// Wait for the shared result, or for this caller to give up
+	ctx := requestContext(options)
+
+	select {
+
+	case <-call.done:
+		return call.result()
+
+	case <-ctx.Done():
+		return streams.NilDocument(), derp.Wrap(ctx.Err(), location, "Request cancelled while waiting for shared result", url)
+	}
+}
+
+// fetch loads a document from the inner client, then releases every caller that is
+// waiting for it.
+func (client *Singleflight) fetch(url string, call *singleflightCall, options []any) {
+
+	const location = "hannibal.clients.Singleflight.fetch"
+
+	// Always release waiting callers, even if the inner client panics
+	defer func() {
+
+		if recovered := recover(); recovered != nil {
+			call.document = streams.NilDocument()
+			call.err = derp.Internal(location, "Shared request did not complete", url, recovered)
+		}
+
+		client.mutex.Lock()
+		delete(client.calls, url)
+		client.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.document, call.err = client.innerClient.Load(url, options...)
+}
+
+// Save passes the document to the inner client.
+func (client *Singleflight) Save(document streams.Document) error {
+	return client.innerClient.Save(document)
+}
+
+// Delete passes the document ID to the inner client.
+func (client *Singleflight) Delete(documentID string) error {
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient passes the top-level client down to the underlying client, so
+// recursive loads made further down the chain are also merged by this client.
+func (client *Singleflight) SetRootClient(rootClient streams.Client) {
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+// result returns a separate copy of the shared result to each caller, so
+// that no caller can modify the document that another caller received.
+func (call *singleflightCall) result() (streams.Document, error) {
+	return call.document.Clone(), call.err
+}
+
+// Verify that Singleflight satisfies the streams.Client interface.
+var _ streams.Client = &Singleflight{}
@@ -0,0 +1,167 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedClient is a streams.Client that counts Load calls, and holds each one until the gate is opened
+type gatedClient struct {
+	mockInnerClient
+	calls   atomic.Int32
+	gate    chan struct{}
+	err     error
+	options []any // Options from the most recent Load call
+}
+
+func (c *gatedClient) Load(url string, options ...any) (streams.Document, error) {
+	c.options = options
+	c.calls.Add(1)
+	<-c.gate
+	return cacheTestDocument(url, http.Header{}), c.err
+}
+
+// TestSingleflight_Merges confirms concurrent Loads of the same URL share one upstream request.
+func TestSingleflight_Merges(t *testing.T) {
+
+	inner := &gatedClient{gate: make(chan struct{})}
+	client := NewSingleflight(inner)
+
+	const callers = 20
+	results := make([]streams.Document, callers)
+
+	var wg sync.WaitGroup
+	for index := range callers {
+		wg.Go(func() {
+			document, err := client.Load("https://example.com/1")
+			assert.NoError(t, err)
+			results[index] = document
+		})
+	}
+
+	// Wait until the first request is in flight (and give the others a chance to join it)
+	for inner.calls.Load() == 0 {
+		runtime.Gosched()
+	}
+
+	close(inner.gate)
+	wg.Wait()
+
+	// Late joiners may start a second request after the first one completes, but
+	// twenty callers must never produce twenty requests.
+	assert.Less(t, int(inner.calls.Load()), callers)
+
+	for _, document := range results {
+		assert.Equal(t, "https://example.com/1", document.ID())
+	}
+
+	// Each caller receives its own copy of the document
+	results[0].SetProperty(vocab.PropertyName, "changed")
+	assert.Equal(t, "", results[1].Name())
+}
+
+// TestSingleflight_SeparateURLs confirms different URLs are not merged, and nothing is cached afterward.
+func TestSingleflight_SeparateURLs(t *testing.T) {
+
+	inner := &gatedClient{gate: make(chan struct{})}
+	close(inner.gate)
+	client := NewSingleflight(inner)
+
+	_, _ = client.Load("https://example.com/1")
+	_, _ = client.Load("https://example.com/2")
+	_, _ = client.Load("https://example.com/1")
+
+	assert.Equal(t, int32(3), inner.calls.Load())
+}
+
+// TestSingleflight_SharedError confirms every merged caller receives the upstream error.
+func TestSingleflight_SharedError(t *testing.T) {
+
+	inner := &gatedClient{gate: make(chan struct{}), err: errors.New("network failure")}
+	client := NewSingleflight(inner)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			_, err := client.Load("https://example.com/1")
+			assert.Error(t, err)
+		})
+	}
+
+	for inner.calls.Load() == 0 {
+		runtime.Gosched()
+	}
+
+	close(inner.gate)
+	wg.Wait()
+}
+
+// TestSingleflight_CallerCancelled confirms a caller stops waiting when its own context is
+// cancelled, without cancelling the shared request for the other callers.
+func TestSingleflight_CallerCancelled(t *testing.T) {
+
+	inner := &gatedClient{gate: make(chan struct{})}
+	client := NewSingleflight(inner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+
+	go func() {
+		_, err := client.Load("https://example.com/1", ctx)
+		leader <- err
+	}()
+
+	for inner.calls.Load() == 0 {
+		runtime.Gosched()
+	}
+
+	follower := make(chan streams.Document)
+
+	go func() {
+		document, err := client.Load("https://example.com/1")
+		assert.NoError(t, err)
+		follower <- document
+	}()
+
+	// The leader gives up, even though the shared request is still in flight
+	cancel()
+	require.ErrorIs(t, <-leader, context.Canceled)
+
+	// The shared request does not inherit the leader's cancellation
+	sharedContext := requestContext(inner.options)
+	require.NoError(t, sharedContext.Err())
+
+	close(inner.gate)
+	assert.Equal(t, "https://example.com/1", (<-follower).ID())
+}
+
+// TestSingleflight_RootClient confirms the wrapper passes the root client through a chain,
+// so that documents loaded deeper in the chain resolve their links through the top.
+func TestSingleflight_RootClient(t *testing.T) {
+
+	inner := &mockInnerClient{}
+	cache := NewCache(inner)
+	client := NewSingleflight(cache)
+	client.SetRootClient(client)
+
+	inner.loadResult = cacheTestDocument("https://example.com/1", http.Header{})
+	document, err := client.Load("https://example.com/1")
+	require.NoError(t, err)
+
+	assert.Same(t, client, document.Client())
+	assert.True(t, inner.rootClientSet)
+
+	// Delegates pass through the whole chain
+	require.NoError(t, client.Delete("https://example.com/1"))
+	assert.Equal(t, "https://example.com/1", inner.deletedID)
+}
@@ -0,0 +1,208 @@
+package instance
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/collection"
+	"github.com/benpate/hannibal/outbox"
+	"github.com/benpate/hannibal/sender"
+	"github.com/benpate/hannibal/sigs"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/rosetta/mapof"
+	"github.com/labstack/echo/v4"
+)
+
+// Actor is the server-wide Application actor. It implements sender.Actor, so it can
+// deliver activities through a Sender, and it publishes its own actor document.
+type Actor struct {
+	baseURL     string           // Root URL of this server (e.g. "https://example.social")
+	path        string           // Path where the actor document is served (defaults to "/actor")
+	username    string           // preferredUsername of the actor (defaults to the server's hostname)
+	name        string           // Display name of the actor
+	summary     string           // Description of the actor, in HTML
+	sharedInbox string           // URL of the server's shared inbox (if any)
+	inbox       echo.HandlerFunc // Handles activities delivered to the actor's inbox (if any)
+	keySize     int              // Size (in bits) of newly generated RSA keys
+	keyStore    KeyStore
+	privateKey  *rsa.PrivateKey
+}
+
+// New returns a fully initialized instance Actor for the server at `baseURL`. If no private key is
+// provided, then one is loaded from the KeyStore, or generated (and saved to the KeyStore) if
+// the KeyStore is empty. Without a KeyStore, a new key is generated every time the server
+// starts, which forces other servers to refetch it.
+func New(baseURL string, options ...Option) (*Actor, error) {
+
+	const location = "hannibal.instance.New"
+
+	parsed, err := url.Parse(baseURL)
+
+	if (err != nil) || (parsed.Host == "") {
+		return nil, derp.BadRequest(location, "Base URL must be an absolute URL", baseURL)
+	}
+
+	result := &Actor{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		path:     PathActor,
+		username: parsed.Hostname(),
+		keySize:  2048,
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	if err := result.loadKey(); err != nil {
+		return nil, derp.Wrap(err, location, "Unable to load private key for instance actor")
+	}
+
+	return result, nil
+}
+
+// ActorID is a part of the sender.Actor interface.
+// It returns the unique ID (URL) of the instance actor
+func (actor *Actor) ActorID() string {
+	return actor.baseURL + actor.path
+}
+
+// PrivateKey is a part of the sender.Actor interface.
+// It returns the public key ID and private key used to sign requests from the instance actor
+func (actor *Actor) PrivateKey() (publicKeyID string, privateKey crypto.PrivateKey) {
+	return actor.PublicKeyID(), actor.privateKey
+}
+
+// PublicKeyID returns the URL of the instance actor's public key
+func (actor *Actor) PublicKeyID() string {
+	return actor.ActorID() + "#main-key"
+}
+
+// Path returns the path where the actor document should be served (for instance, "/actor").
+// Its inbox and outbox are served at "{path}/inbox" and "{path}/outbox".
+func (actor *Actor) Path() string {
+	return actor.path
+}
+
+// Signer returns a sigs.Signer that signs GET requests (for "authorized fetch") as the instance actor
+func (actor *Actor) Signer() sigs.Signer {
+	return sigs.NewSigner(actor.PublicKeyID(), actor.privateKey,
+		sigs.SignerFields(sigs.FieldRequestTarget, sigs.FieldHost, sigs.FieldDate),
+	)
+}
+
+// Outbox returns an outbox.Actor that sends activities (such as Flags, or Follows
+// to a relay) as the instance actor.
+func (actor *Actor) Outbox(options ...outbox.ActorOption) outbox.Actor {
+	options = append([]outbox.ActorOption{outbox.WithPublicKey(actor.PublicKeyID())}, options...)
+	return outbox.NewActor(actor.ActorID(), actor.privateKey, options...)
+}
+
+// Profile returns the instance actor's public ActivityPub document
+func (actor *Actor) Profile() (mapof.Any, error) {
+	outboxActor := actor.Outbox()
+	return outboxActor.Profile(actor.profile())
+}
+
+// Serve writes the instance actor's public ActivityPub document to the HTTP response.
+// Register it at Path() (for instance, e.GET(actor.Path(), actor.Serve))
+func (actor *Actor) Serve(ctx echo.Context) error {
+	outboxActor := actor.Outbox()
+	return outboxActor.ServeProfile(ctx, actor.profile())
+}
+
+// ServeOutbox writes the instance actor's (always empty) outbox to the HTTP response.
+// Register it at Path() + "/outbox"
+func (actor *Actor) ServeOutbox(ctx echo.Context) error {
+	return collection.ServeEmpty(ctx, actor.ActorID()+"/outbox")
+}
+
+// ServeInbox receives activities delivered to the instance actor's inbox, using the handler
+// from WithInbox. Without one, activities are accepted and ignored.
+// Register it at Path() + "/inbox" (for instance, e.POST(actor.Path()+"/inbox", actor.ServeInbox))
+func (actor *Actor) ServeInbox(ctx echo.Context) error {
+
+	if actor.inbox != nil {
+		return actor.inbox(ctx)
+	}
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// profile returns the public information about the instance actor
+func (actor *Actor) profile() outbox.ActorProfile {
+	return outbox.ActorProfile{
+		Type:                      vocab.ActorTypeApplication,
+		Name:                      actor.name,
+		PreferredUsername:         actor.username,
+		Summary:                   actor.summary,
+		URL:                       actor.baseURL,
+		Inbox:                     actor.ActorID() + "/inbox",
+		Outbox:                    actor.ActorID() + "/outbox",
+		SharedInbox:               actor.sharedInbox,
+		ManuallyApprovesFollowers: true,
+	}
+}
+
+// loadKey loads the private key from the KeyStore, or generates a new one if none exists
+func (actor *Actor) loadKey() error {
+
+	const location = "hannibal.instance.Actor.loadKey"
+
+	// Keys passed in directly are used as-is
+	if actor.privateKey != nil {
+		return nil
+	}
+
+	// Try to load an existing key
+	if actor.keyStore != nil {
+
+		privatePEM, err := actor.keyStore.LoadKey()
+
+		if err != nil {
+			return derp.Wrap(err, location, "Unable to load key from KeyStore")
+		}
+
+		if privatePEM != "" {
+
+			privateKey, err := sigs.DecodePrivatePEM(privatePEM)
+
+			if err != nil {
+				return derp.Wrap(err, location, "Unable to decode private key")
+			}
+
+			rsaKey, ok := privateKey.(*rsa.PrivateKey)
+
+			if !ok {
+				return derp.Internal(location, "Instance actor requires an RSA private key")
+			}
+
+			actor.privateKey = rsaKey
+			return nil
+		}
+	}
+
+	// Otherwise, generate a new key
+	privateKey, err := rsa.GenerateKey(rand.Reader, actor.keySize)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to generate private key")
+	}
+
+	// Save the new key so that it survives a restart
+	if actor.keyStore != nil {
+		if err := actor.keyStore.SaveKey(sigs.EncodePrivatePEM(privateKey)); err != nil {
+			return derp.Wrap(err, location, "Unable to save key to KeyStore")
+		}
+	}
+
+	actor.privateKey = privateKey
+	return nil
+}
+
+// Verify that Actor satisfies the sender.Actor interface.
+var _ sender.Actor = &Actor{}
@@ -0,0 +1,196 @@
+package instance
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benpate/hannibal/sigs"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryKeyStore is a KeyStore that keeps the key in memory
+type memoryKeyStore struct {
+	privatePEM string
+	saves      int
+	err        error
+}
+
+func (store *memoryKeyStore) LoadKey() (string, error) {
+	return store.privatePEM, store.err
+}
+
+func (store *memoryKeyStore) SaveKey(privatePEM string) error {
+	store.privatePEM = privatePEM
+	store.saves++
+	return nil
+}
+
+// TestNew confirms the default settings, and that a new key is generated and saved.
+func TestNew(t *testing.T) {
+
+	store := &memoryKeyStore{}
+	actor, err := New("https://example.social/", WithKeyStore(store), WithKeySize(1024))
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://example.social/actor", actor.ActorID())
+	assert.Equal(t, "https://example.social/actor#main-key", actor.PublicKeyID())
+	assert.Equal(t, "/actor", actor.Path())
+	assert.Equal(t, 1, store.saves)
+
+	publicKeyID, privateKey := actor.PrivateKey()
+	assert.Equal(t, "https://example.social/actor#main-key", publicKeyID)
+	require.IsType(t, &rsa.PrivateKey{}, privateKey)
+	assert.Equal(t, 1024, privateKey.(*rsa.PrivateKey).N.BitLen())
+
+	// The saved key is reused on the next start
+	restarted, err := New("https://example.social", WithKeyStore(store))
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.saves)
+	assert.True(t, actor.privateKey.Equal(restarted.privateKey))
+}
+
+// TestNew_Errors confirms invalid base URLs and KeyStore failures are reported.
+func TestNew_Errors(t *testing.T) {
+
+	_, err := New("example.social")
+	require.Error(t, err)
+
+	_, err = New("https://example.social", WithKeyStore(&memoryKeyStore{err: errors.New("disk is full")}))
+	require.Error(t, err)
+
+	_, err = New("https://example.social", WithKeyStore(&memoryKeyStore{privatePEM: "not a key"}))
+	require.Error(t, err)
+}
+
+// TestFileKeyStore confirms keys survive a restart when stored on disk.
+func TestFileKeyStore(t *testing.T) {
+
+	store := NewFileKeyStore(filepath.Join(t.TempDir(), "instance.pem"))
+
+	privatePEM, err := store.LoadKey()
+	require.NoError(t, err)
+	assert.Empty(t, privatePEM)
+
+	first, err := New("https://example.social", WithKeyStore(store), WithKeySize(1024))
+	require.NoError(t, err)
+
+	second, err := New("https://example.social", WithKeyStore(store))
+	require.NoError(t, err)
+
+	assert.True(t, first.privateKey.Equal(second.privateKey))
+}
+
+// TestActor_Profile confirms the actor document describes an Application with its public key.
+func TestActor_Profile(t *testing.T) {
+
+	actor, err := New("https://example.social",
+		WithPath("instance"),
+		WithName("Example Social"),
+		WithSharedInbox("https://example.social/inbox"),
+		WithKeySize(1024),
+	)
+	require.NoError(t, err)
+
+	result, err := actor.Profile()
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://example.social/instance", result.GetString(vocab.PropertyID))
+	assert.Equal(t, vocab.ActorTypeApplication, result.GetString(vocab.PropertyType))
+	assert.Equal(t, "example.social", result.GetString(vocab.PropertyPreferredUsername))
+	assert.Equal(t, "Example Social", result.GetString(vocab.PropertyName))
+	assert.Equal(t, "https://example.social/instance/inbox", result.GetString(vocab.PropertyInbox))
+	assert.Equal(t, "https://example.social/instance/outbox", result.GetString(vocab.PropertyOutbox))
+	assert.Equal(t, "https://example.social/inbox", result.GetMap(vocab.PropertyEndpoints).GetString(vocab.EndpointSharedInbox))
+	assert.True(t, result.GetBool(vocab.PropertyManuallyApprovesFollowers))
+	assert.Equal(t, sigs.EncodePublicPEM(actor.privateKey), result.GetMap(vocab.PropertyPublicKey).GetString(vocab.PropertyPublicKeyPEM))
+}
+
+// TestActor_Serve confirms the actor document and empty outbox are served over HTTP.
+func TestActor_Serve(t *testing.T) {
+
+	actor, err := New("https://example.social", WithKeySize(1024))
+	require.NoError(t, err)
+
+	serve := func(handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Accept", vocab.ContentTypeActivityPub)
+		recorder := httptest.NewRecorder()
+		require.NoError(t, handler(echo.New().NewContext(request, recorder)))
+		return recorder
+	}
+
+	profile := serve(actor.Serve)
+	assert.Equal(t, http.StatusOK, profile.Code)
+	assert.Contains(t, profile.Body.String(), `"https://example.social/actor"`)
+
+	outbox := serve(actor.ServeOutbox)
+	assert.Equal(t, http.StatusOK, outbox.Code)
+	assert.Contains(t, outbox.Body.String(), `"totalItems":0`)
+}
+
+// TestActor_ServeInbox confirms the inbox advertised in the actor document is served,
+// and that activities are passed to the handler from WithInbox.
+func TestActor_ServeInbox(t *testing.T) {
+
+	received := 0
+
+	inbox := func(ctx echo.Context) error {
+		received++
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	for _, test := range []struct {
+		options  []Option
+		expected int
+	}{
+		{options: nil, expected: http.StatusAccepted},
+		{options: []Option{WithInbox(inbox)}, expected: http.StatusOK},
+	} {
+		actor, err := New("https://example.social", append(test.options, WithKeySize(1024))...)
+		require.NoError(t, err)
+
+		e := echo.New()
+		e.POST(actor.Path()+"/inbox", actor.ServeInbox)
+
+		profile, err := actor.Profile()
+		require.NoError(t, err)
+
+		inboxURL, err := url.Parse(profile.GetString(vocab.PropertyInbox))
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodPost, inboxURL.Path, strings.NewReader(`{"type":"Accept"}`))
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, request)
+
+		assert.Equal(t, test.expected, recorder.Code)
+	}
+
+	assert.Equal(t, 1, received)
+}
+
+// TestActor_Signer confirms signed GETs can be verified with the published public key.
+func TestActor_Signer(t *testing.T) {
+
+	actor, err := New("https://example.social", WithKeySize(1024))
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, "https://remote.example/users/bob", nil)
+	request.Header.Set("Date", "Mon, 01 Jan 2024 00:00:00 GMT")
+
+	signer := actor.Signer()
+	require.NoError(t, signer.Sign(request))
+
+	signature, err := sigs.ParseSignature(request.Header.Get("Signature"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.social/actor#main-key", signature.KeyID)
+	assert.Equal(t, []string{sigs.FieldRequestTarget, sigs.FieldHost, sigs.FieldDate}, signature.Headers)
+}
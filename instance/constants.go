@@ -0,0 +1,4 @@
+package instance
+
+// PathActor is the default path for the instance actor's document
+const PathActor = "/actor"
@@ -0,0 +1,4 @@
+// Package instance provides a server-wide "instance actor": an ActivityPub Application actor
+// that represents the server itself. Use it to sign fetches that are not made on behalf of
+// any user, to subscribe to relays, and to send server-level reports (such as Flags).
+package instance
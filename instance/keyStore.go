@@ -0,0 +1,67 @@
+package instance
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/benpate/derp"
+)
+
+// KeyStore keeps the instance actor's private key (PEM encoded) between restarts
+type KeyStore interface {
+
+	// LoadKey returns the saved private key, or an empty string if no key has been saved yet
+	LoadKey() (string, error)
+
+	// SaveKey saves a newly generated private key
+	SaveKey(privatePEM string) error
+}
+
+// FileKeyStore is a KeyStore that keeps the private key in a file on disk
+type FileKeyStore struct {
+	filename string
+}
+
+// NewFileKeyStore returns a KeyStore that keeps the private key in the named file.
+// The file is created (readable only by its owner) when the first key is saved.
+func NewFileKeyStore(filename string) FileKeyStore {
+	return FileKeyStore{
+		filename: filename,
+	}
+}
+
+// LoadKey is a part of the KeyStore interface.
+// It returns the contents of the file, or an empty string if the file does not exist.
+func (store FileKeyStore) LoadKey() (string, error) {
+
+	const location = "hannibal.instance.FileKeyStore.LoadKey"
+
+	data, err := os.ReadFile(store.filename)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", derp.Wrap(err, location, "Unable to read key file", store.filename)
+	}
+
+	return string(data), nil
+}
+
+// SaveKey is a part of the KeyStore interface.
+// It writes the private key to the file.
+func (store FileKeyStore) SaveKey(privatePEM string) error {
+
+	const location = "hannibal.instance.FileKeyStore.SaveKey"
+
+	if err := os.WriteFile(store.filename, []byte(privatePEM), 0o600); err != nil {
+		return derp.Wrap(err, location, "Unable to write key file", store.filename)
+	}
+
+	return nil
+}
+
+// Verify that FileKeyStore satisfies the KeyStore interface.
+var _ KeyStore = FileKeyStore{}
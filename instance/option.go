@@ -0,0 +1,75 @@
+package instance
+
+import (
+	"crypto/rsa"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Option is a functional option that configures an instance Actor
+type Option func(*Actor)
+
+// WithPath sets the path where the instance actor is served (defaults to "/actor")
+func WithPath(path string) Option {
+	return func(actor *Actor) {
+		actor.path = "/" + strings.TrimPrefix(path, "/")
+	}
+}
+
+// WithUsername sets the preferredUsername of the instance actor (defaults to the server's hostname)
+func WithUsername(username string) Option {
+	return func(actor *Actor) {
+		actor.username = username
+	}
+}
+
+// WithName sets the display name of the instance actor
+func WithName(name string) Option {
+	return func(actor *Actor) {
+		actor.name = name
+	}
+}
+
+// WithSummary sets the HTML description of the instance actor
+func WithSummary(summary string) Option {
+	return func(actor *Actor) {
+		actor.summary = summary
+	}
+}
+
+// WithSharedInbox sets the URL of the server's shared inbox, which is published in the actor's endpoints
+func WithSharedInbox(sharedInbox string) Option {
+	return func(actor *Actor) {
+		actor.sharedInbox = sharedInbox
+	}
+}
+
+// WithInbox sets the handler for activities delivered to the instance actor's inbox,
+// such as a router.Router's EchoHandler
+func WithInbox(handler echo.HandlerFunc) Option {
+	return func(actor *Actor) {
+		actor.inbox = handler
+	}
+}
+
+// WithKeyStore sets the KeyStore that keeps the instance actor's private key between restarts
+func WithKeyStore(keyStore KeyStore) Option {
+	return func(actor *Actor) {
+		actor.keyStore = keyStore
+	}
+}
+
+// WithKeySize sets the size (in bits) of newly generated RSA keys (defaults to 2048)
+func WithKeySize(bits int) Option {
+	return func(actor *Actor) {
+		actor.keySize = bits
+	}
+}
+
+// WithPrivateKey sets the instance actor's private key directly, skipping the KeyStore
+func WithPrivateKey(privateKey *rsa.PrivateKey) Option {
+	return func(actor *Actor) {
+		actor.privateKey = privateKey
+	}
+}
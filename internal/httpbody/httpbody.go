@@ -0,0 +1,38 @@
+// Package httpbody contains the remote.Option that hannibal's protocol clients (WebFinger,
+// NodeInfo) use to read raw response bodies.
+package httpbody
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/remote"
+)
+
+// Read is a remote.Option that copies up to `maxSize` bytes of the response body into `body`,
+// leaving the original body in place for any other options to read.
+func Read(body *[]byte, maxSize int64) remote.Option {
+
+	const location = "hannibal.internal.httpbody.Read"
+
+	return remote.Option{
+		AfterRequest: func(_ *remote.Transaction, response *http.Response) error {
+
+			if (response == nil) || (response.Body == nil) {
+				return nil
+			}
+
+			data, err := io.ReadAll(io.LimitReader(response.Body, maxSize))
+
+			if err != nil {
+				return derp.Wrap(err, location, "Unable to read response body")
+			}
+
+			*body = data
+			response.Body = io.NopCloser(bytes.NewReader(data))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package httpbody
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRead confirms the body is copied (up to the size limit) and left in place for other readers.
+func TestRead(t *testing.T) {
+
+	var body []byte
+	response := &http.Response{Body: io.NopCloser(strings.NewReader("hello world"))}
+
+	require.NoError(t, Read(&body, 5).AfterRequest(nil, response))
+	assert.Equal(t, "hello", string(body))
+
+	remaining, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(remaining))
+}
+
+// TestRead_NoBody confirms responses without a body are ignored.
+func TestRead_NoBody(t *testing.T) {
+
+	var body []byte
+
+	require.NoError(t, Read(&body, 5).AfterRequest(nil, nil))
+	require.NoError(t, Read(&body, 5).AfterRequest(nil, &http.Response{}))
+	assert.Nil(t, body)
+}
@@ -21,6 +21,7 @@ type Metadata struct {
 	Replies          int64  `bson:"replies,omitempty"`          // Replies is the number of replies to this document
 	Announces        int64  `bson:"announces,omitempty"`        // Announces is the number of times this document has been announced / reposted
 	Likes            int64  `bson:"likes,omitempty"`            // Likes is the number of times this document has been liked
+	OriginCheck      string `bson:"originCheck,omitempty"`      // Result of comparing the document's ID with the URL it was fetched from [VERIFIED, REFETCHED]. Empty if never checked
 
 	// Labels is the current viewer's moderation verdict for this document. The single bson/json "-"
 	// tag keeps EVERYTHING inside it out of shared caches and off the wire, so fields added to
@@ -62,6 +63,17 @@ func (metadata Metadata) HasLikes() bool {
 	return metadata.Likes > 0
 }
 
+// IsOriginVerified returns TRUE if this document's ID has been confirmed to belong to the
+// server that it was loaded from, either directly or by refetching it from its ID. It is set by
+// the clients.OriginVerifier, so it is always FALSE for documents loaded without one.
+func (metadata Metadata) IsOriginVerified() bool {
+	switch metadata.OriginCheck {
+	case OriginCheckVerified, OriginCheckRefetched:
+		return true
+	}
+	return false
+}
+
 // HasRelationship returns TRUE if this document has a relationship
 func (metadata Metadata) HasRelationship() bool {
 	if metadata.RelationType == "" {
@@ -62,6 +62,15 @@ func TestMetadata_HasRelationship(t *testing.T) {
 	assert.False(t, Metadata{}.HasRelationship())
 }
 
+// TestMetadata_IsOriginVerified confirms both successful origin checks count as
+// verified, and that an unchecked document does not.
+func TestMetadata_IsOriginVerified(t *testing.T) {
+
+	assert.True(t, Metadata{OriginCheck: OriginCheckVerified}.IsOriginVerified())
+	assert.True(t, Metadata{OriginCheck: OriginCheckRefetched}.IsOriginVerified())
+	assert.False(t, Metadata{}.IsOriginVerified())
+}
+
 // TestMetadata_SetRelationCount confirms the setter updates the matching counter
 // and reports whether the value actually changed.
 func TestMetadata_SetRelationCount(t *testing.T) {
@@ -0,0 +1,8 @@
+package metadata
+
+// OriginCheckVerified means that the document's ID has the same origin as the URL it was loaded from
+const OriginCheckVerified = "VERIFIED"
+
+// OriginCheckRefetched means that the document's ID did NOT match the URL it was loaded from,
+// so the document was loaded again from its ID, which returned the same ID.
+const OriginCheckRefetched = "REFETCHED"
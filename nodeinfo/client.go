@@ -0,0 +1,121 @@
+package nodeinfo
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/internal/httpbody"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/remote"
+)
+
+// Client reads NodeInfo documents from remote servers
+type Client struct {
+	options []remote.Option // Options applied to every request
+	scheme  string          // Scheme used to contact remote servers (always "https" outside of tests)
+}
+
+// NewClient returns a fully initialized Client. The remote.Options are applied to every request.
+func NewClient(options ...remote.Option) *Client {
+	return &Client{
+		options: options,
+		scheme:  "https",
+	}
+}
+
+// Load returns the NodeInfo document for a remote host (such as "example.social"). It reads
+// the host's discovery document, then loads the newest NodeInfo version that it links to.
+func (client *Client) Load(host string) (NodeInfo, error) {
+
+	const location = "hannibal.nodeinfo.Client.Load"
+
+	// Load the discovery document
+	discoveryURL := client.scheme + "://" + host + PathDiscovery
+	discovery := struct {
+		Links []link `json:"links"`
+	}{}
+
+	if err := client.get(discoveryURL, &discovery); err != nil {
+		return NodeInfo{}, derp.Wrap(err, location, "Unable to load NodeInfo discovery document", discoveryURL)
+	}
+
+	// Find the newest schema version
+	links := slices.DeleteFunc(discovery.Links, func(link link) bool {
+		_, isSchema := parseSchemaVersion(link.Rel)
+		return !isSchema || (link.Href == "")
+	})
+
+	if len(links) == 0 {
+		return NodeInfo{}, derp.NotFound(location, "NodeInfo discovery document does not link to a NodeInfo schema", discoveryURL)
+	}
+
+	newest := slices.MaxFunc(links, func(a link, b link) int {
+		versionA, _ := parseSchemaVersion(a.Rel)
+		versionB, _ := parseSchemaVersion(b.Rel)
+		return slices.Compare(versionA, versionB)
+	})
+
+	// Load the NodeInfo document
+	result := NodeInfo{}
+
+	if err := client.get(newest.Href, &result); err != nil {
+		return NodeInfo{}, derp.Wrap(err, location, "Unable to load NodeInfo document", newest.Href)
+	}
+
+	return result, nil
+}
+
+// parseSchemaVersion returns the numeric version (such as [2, 1]) of a NodeInfo schema link
+// relation, and TRUE if the relation is a NodeInfo schema at all. Both the "http" and "https"
+// forms of the schema URL are accepted.
+func parseSchemaVersion(rel string) ([]int, bool) {
+
+	version, found := strings.CutPrefix(rel, SchemaPrefix)
+
+	if !found {
+		if version, found = strings.CutPrefix(rel, schemaPrefixHTTPS); !found {
+			return nil, false
+		}
+	}
+
+	parts := strings.Split(version, ".")
+	result := make([]int, len(parts))
+
+	for index, part := range parts {
+		number, err := strconv.Atoi(part)
+
+		if (err != nil) || (number < 0) {
+			return nil, false
+		}
+
+		result[index] = number
+	}
+
+	return result, true
+}
+
+// get loads a JSON document from a remote server
+func (client *Client) get(uri string, result any) error {
+
+	const location = "hannibal.nodeinfo.Client.get"
+
+	var body []byte
+
+	transaction := remote.Get(uri).
+		Accept(vocab.ContentTypeJSON).
+		With(client.options...).
+		With(httpbody.Read(&body, maxBodySize))
+
+	if err := transaction.Send(); err != nil {
+		return derp.Wrap(err, location, "Unable to complete request", uri)
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return derp.Wrap(err, location, "Unable to parse response", uri)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package nodeinfo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a NodeInfo Client that can reach the local test server over HTTP
+func newTestClient() *Client {
+
+	allowPrivateIPs := remote.Option{
+		BeforeRequest: func(txn *remote.Transaction) error {
+			txn.AllowPrivateIPs(true)
+			return nil
+		},
+	}
+
+	result := NewClient(allowPrivateIPs)
+	result.scheme = "http"
+	return result
+}
+
+// newTestServer returns the host name of a test server that serves the provided JSON documents.
+// Each "{host}" in a document is replaced with the server's host name.
+func newTestServer(t *testing.T, documents map[string]string) string {
+
+	mux := http.NewServeMux()
+
+	for path, document := range documents {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(strings.ReplaceAll(document, "{host}", r.Host)))
+		})
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// TestClient_Load confirms the client follows the discovery document to the newest schema version.
+func TestClient_Load(t *testing.T) {
+
+	host := newTestServer(t, map[string]string{
+		PathDiscovery: `{"links":[
+			{"rel":"http://nodeinfo.diaspora.software/ns/schema/2.0","href":"http://{host}/nodeinfo/2.0"},
+			{"rel":"http://nodeinfo.diaspora.software/ns/schema/2.1","href":"http://{host}/nodeinfo/2.1"},
+			{"rel":"https://example.social/not-nodeinfo","href":"http://{host}/other"}
+		]}`,
+		"/nodeinfo/2.1": `{
+			"version":"2.1",
+			"software":{"name":"mastodon","version":"4.3.0","repository":"https://github.com/mastodon/mastodon"},
+			"protocols":["activitypub"],
+			"services":{"inbound":[],"outbound":[]},
+			"openRegistrations":false,
+			"usage":{"users":{"total":1200,"activeHalfyear":800,"activeMonth":300},"localPosts":45000},
+			"metadata":{"nodeName":"Example Social"}
+		}`,
+		"/nodeinfo/2.0": `{"version":"2.0"}`,
+	})
+
+	result, err := newTestClient().Load(host)
+	require.NoError(t, err)
+
+	assert.Equal(t, "2.1", result.Version)
+	assert.Equal(t, Software{Name: "mastodon", Version: "4.3.0", Repository: "https://github.com/mastodon/mastodon"}, result.Software)
+	assert.Equal(t, Users{Total: 1200, ActiveHalfyear: 800, ActiveMonth: 300}, result.Usage.Users)
+	assert.Equal(t, int64(45000), result.Usage.LocalPosts)
+	assert.Equal(t, "Example Social", result.Metadata["nodeName"])
+	assert.False(t, result.OpenRegistrations)
+
+	assert.True(t, result.IsSoftware("Pleroma", "Mastodon"))
+	assert.False(t, result.IsSoftware("misskey"))
+	assert.True(t, result.SupportsProtocol(ProtocolActivityPub))
+}
+
+// TestClient_Load_VersionOrder confirms versions are compared numerically (so "2.10" is newer
+// than "2.2"), and that "https" schema links are recognized too.
+func TestClient_Load_VersionOrder(t *testing.T) {
+
+	host := newTestServer(t, map[string]string{
+		PathDiscovery: `{"links":[
+			{"rel":"http://nodeinfo.diaspora.software/ns/schema/2.2","href":"http://{host}/nodeinfo/2.2"},
+			{"rel":"https://nodeinfo.diaspora.software/ns/schema/2.10","href":"http://{host}/nodeinfo/2.10"},
+			{"rel":"http://nodeinfo.diaspora.software/ns/schema/latest","href":"http://{host}/nodeinfo/latest"}
+		]}`,
+		"/nodeinfo/2.2":    `{"version":"2.2"}`,
+		"/nodeinfo/2.10":   `{"version":"2.10"}`,
+		"/nodeinfo/latest": `{"version":"latest"}`,
+	})
+
+	result, err := newTestClient().Load(host)
+	require.NoError(t, err)
+	assert.Equal(t, "2.10", result.Version)
+}
+
+// TestParseSchemaVersion confirms which link relations are NodeInfo schemas, and their versions.
+func TestParseSchemaVersion(t *testing.T) {
+
+	check := func(rel string, expected []int, expectedOK bool) {
+		version, ok := parseSchemaVersion(rel)
+		assert.Equal(t, expected, version, rel)
+		assert.Equal(t, expectedOK, ok, rel)
+	}
+
+	check("http://nodeinfo.diaspora.software/ns/schema/2.1", []int{2, 1}, true)
+	check("https://nodeinfo.diaspora.software/ns/schema/2.10", []int{2, 10}, true)
+	check("http://nodeinfo.diaspora.software/ns/schema/1", []int{1}, true)
+	check("http://nodeinfo.diaspora.software/ns/schema/2.x", nil, false)
+	check("http://nodeinfo.diaspora.software/ns/schema/", nil, false)
+	check("https://example.social/ns/schema/2.1", nil, false)
+}
+
+// TestClient_Load_NoSchema confirms discovery documents without NodeInfo links return "404 Not Found".
+func TestClient_Load_NoSchema(t *testing.T) {
+
+	host := newTestServer(t, map[string]string{
+		PathDiscovery: `{"links":[{"rel":"https://example.social/not-nodeinfo","href":"http://{host}/other"}]}`,
+	})
+
+	_, err := newTestClient().Load(host)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, derp.ErrorCode(err))
+}
+
+// TestClient_Load_Missing confirms servers without NodeInfo return an error.
+func TestClient_Load_Missing(t *testing.T) {
+
+	host := newTestServer(t, map[string]string{})
+
+	_, err := newTestClient().Load(host)
+	require.Error(t, err)
+}
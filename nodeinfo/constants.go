@@ -0,0 +1,22 @@
+package nodeinfo
+
+// PathDiscovery is the standard location of a server's NodeInfo discovery document
+const PathDiscovery = "/.well-known/nodeinfo"
+
+// SchemaPrefix is the beginning of every NodeInfo schema link relation. The version number follows it.
+const SchemaPrefix = "http://nodeinfo.diaspora.software/ns/schema/"
+
+// schemaPrefixHTTPS is the "https" variant of SchemaPrefix, which some servers publish instead
+const schemaPrefixHTTPS = "https://nodeinfo.diaspora.software/ns/schema/"
+
+// Version20 is NodeInfo schema version 2.0
+const Version20 = "2.0"
+
+// Version21 is NodeInfo schema version 2.1, which adds the software's repository and homepage
+const Version21 = "2.1"
+
+// ProtocolActivityPub is the NodeInfo protocol name for ActivityPub
+const ProtocolActivityPub = "activitypub"
+
+// maxBodySize is the largest response that will be read from a NodeInfo request
+const maxBodySize = 1 << 20 // 1 MB
@@ -0,0 +1,4 @@
+// Package nodeinfo publishes this server's NodeInfo document (versions 2.0 and 2.1), and
+// reads NodeInfo from remote servers, so that peers can tell which software they are
+// talking to. https://nodeinfo.diaspora.software
+package nodeinfo
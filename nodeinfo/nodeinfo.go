@@ -0,0 +1,58 @@
+package nodeinfo
+
+import (
+	"slices"
+	"strings"
+)
+
+// NodeInfo is a NodeInfo 2.0 or 2.1 document that describes a server
+// https://github.com/jhass/nodeinfo/blob/main/schemas/2.1/schema.json
+type NodeInfo struct {
+	Version           string         `json:"version"`
+	Software          Software       `json:"software"`
+	Protocols         []string       `json:"protocols"`
+	Services          Services       `json:"services"`
+	OpenRegistrations bool           `json:"openRegistrations"`
+	Usage             Usage          `json:"usage"`
+	Metadata          map[string]any `json:"metadata"`
+}
+
+// Software describes the software that a server is running
+type Software struct {
+	Name       string `json:"name"`                 // Canonical name, in lowercase (e.g. "mastodon")
+	Version    string `json:"version"`              // Version of the software (e.g. "4.3.0")
+	Repository string `json:"repository,omitempty"` // URL of the source code repository (2.1 only)
+	Homepage   string `json:"homepage,omitempty"`   // URL of the software's homepage (2.1 only)
+}
+
+// Services lists the third-party sites that a server can import from (inbound) or publish to (outbound)
+type Services struct {
+	Inbound  []string `json:"inbound"`
+	Outbound []string `json:"outbound"`
+}
+
+// Usage reports how many people use a server, and how much they have posted
+type Usage struct {
+	Users         Users `json:"users"`
+	LocalPosts    int64 `json:"localPosts"`
+	LocalComments int64 `json:"localComments,omitempty"`
+}
+
+// Users counts the people with accounts on a server
+type Users struct {
+	Total          int64 `json:"total"`
+	ActiveHalfyear int64 `json:"activeHalfyear"`
+	ActiveMonth    int64 `json:"activeMonth"`
+}
+
+// IsSoftware returns TRUE if the server is running any of the named software (case-insensitive)
+func (nodeInfo NodeInfo) IsSoftware(names ...string) bool {
+	return slices.ContainsFunc(names, func(name string) bool {
+		return strings.EqualFold(name, nodeInfo.Software.Name)
+	})
+}
+
+// SupportsProtocol returns TRUE if the server supports the named protocol (e.g. ProtocolActivityPub)
+func (nodeInfo NodeInfo) SupportsProtocol(protocol string) bool {
+	return slices.Contains(nodeInfo.Protocols, protocol)
+}
@@ -0,0 +1,17 @@
+package nodeinfo
+
+// Provider supplies the information that this server publishes in its NodeInfo document
+type Provider interface {
+
+	// Software returns the name and version of the software that this server is running
+	Software() Software
+
+	// Usage returns current user and post counts
+	Usage() (Usage, error)
+
+	// OpenRegistrations returns TRUE if new users can sign up without an invitation
+	OpenRegistrations() bool
+
+	// Metadata returns any other free-form information about this server (such as its name)
+	Metadata() map[string]any
+}
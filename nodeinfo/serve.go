@@ -0,0 +1,94 @@
+package nodeinfo
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/benpate/derp"
+	"github.com/labstack/echo/v4"
+)
+
+// link is a single link in the NodeInfo discovery document
+type link struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// ServeDiscovery answers requests for the NodeInfo discovery document (GET /.well-known/nodeinfo),
+// which links to NodeInfo 2.1 and 2.0 documents at "{baseURL}/nodeinfo/2.1" and "{baseURL}/nodeinfo/2.0"
+func ServeDiscovery(ctx echo.Context, baseURL string) error {
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	result := map[string]any{
+		"links": []link{
+			{Rel: SchemaPrefix + Version21, Href: baseURL + "/nodeinfo/" + Version21},
+			{Rel: SchemaPrefix + Version20, Href: baseURL + "/nodeinfo/" + Version20},
+		},
+	}
+
+	ctx.Response().Header().Set(echo.HeaderAccessControlAllowOrigin, "*")
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// Serve answers requests for the NodeInfo document in the requested schema version
+// (Version20 or Version21), using the information from the Provider. Unsupported
+// versions return "404 Not Found".
+func Serve(ctx echo.Context, provider Provider, version string) error {
+
+	const location = "hannibal.nodeinfo.Serve"
+
+	if (version != Version20) && (version != Version21) {
+		return ctx.String(http.StatusNotFound, "Unsupported NodeInfo version")
+	}
+
+	result, err := New(provider, version)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to generate NodeInfo document")
+	}
+
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderAccessControlAllowOrigin, "*")
+	header.Set(echo.HeaderContentType, `application/json; profile="`+SchemaPrefix+version+`#"`)
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// New generates a NodeInfo document in the requested schema version (Version20 or Version21)
+// using the information from the Provider.
+func New(provider Provider, version string) (NodeInfo, error) {
+
+	const location = "hannibal.nodeinfo.New"
+
+	usage, err := provider.Usage()
+
+	if err != nil {
+		return NodeInfo{}, derp.Wrap(err, location, "Unable to calculate usage statistics")
+	}
+
+	software := provider.Software()
+	software.Name = strings.ToLower(software.Name)
+
+	// Repository and Homepage were added in 2.1
+	if version == Version20 {
+		software.Repository = ""
+		software.Homepage = ""
+	}
+
+	metadata := provider.Metadata()
+
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	return NodeInfo{
+		Version:           version,
+		Software:          software,
+		Protocols:         []string{ProtocolActivityPub},
+		Services:          Services{Inbound: []string{}, Outbound: []string{}},
+		OpenRegistrations: provider.OpenRegistrations(),
+		Usage:             usage,
+		Metadata:          metadata,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package nodeinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProvider is a Provider that returns fixed values
+type testProvider struct {
+	usageErr error
+}
+
+func (provider testProvider) Software() Software {
+	return Software{
+		Name:       "Hannibal",
+		Version:    "1.0.0",
+		Repository: "https://github.com/benpate/hannibal",
+		Homepage:   "https://example.social/about",
+	}
+}
+
+func (provider testProvider) Usage() (Usage, error) {
+	return Usage{Users: Users{Total: 10, ActiveHalfyear: 5, ActiveMonth: 2}, LocalPosts: 100}, provider.usageErr
+}
+
+func (provider testProvider) OpenRegistrations() bool {
+	return true
+}
+
+func (provider testProvider) Metadata() map[string]any {
+	return nil
+}
+
+// serveTest sends a request to the provided handler and returns the recorded response
+func serveTest(target string, handler func(echo.Context) error) (*httptest.ResponseRecorder, error) {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	recorder := httptest.NewRecorder()
+	err := handler(echo.New().NewContext(request, recorder))
+	return recorder, err
+}
+
+// TestServeDiscovery confirms the discovery document links to both supported versions.
+func TestServeDiscovery(t *testing.T) {
+
+	recorder, err := serveTest(PathDiscovery, func(ctx echo.Context) error {
+		return ServeDiscovery(ctx, "https://example.social/")
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+
+	discovery := struct {
+		Links []link `json:"links"`
+	}{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &discovery))
+
+	assert.Equal(t, []link{
+		{Rel: "http://nodeinfo.diaspora.software/ns/schema/2.1", Href: "https://example.social/nodeinfo/2.1"},
+		{Rel: "http://nodeinfo.diaspora.software/ns/schema/2.0", Href: "https://example.social/nodeinfo/2.0"},
+	}, discovery.Links)
+}
+
+// TestServe confirms a NodeInfo 2.1 document is built from the Provider.
+func TestServe(t *testing.T) {
+
+	recorder, err := serveTest("/nodeinfo/2.1", func(ctx echo.Context) error {
+		return Serve(ctx, testProvider{}, Version21)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `application/json; profile="http://nodeinfo.diaspora.software/ns/schema/2.1#"`, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+
+	result := map[string]any{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+
+	assert.Equal(t, "2.1", result["version"])
+	assert.Equal(t, map[string]any{
+		"name":       "hannibal",
+		"version":    "1.0.0",
+		"repository": "https://github.com/benpate/hannibal",
+		"homepage":   "https://example.social/about",
+	}, result["software"])
+	assert.Equal(t, []any{"activitypub"}, result["protocols"])
+	assert.Equal(t, map[string]any{"inbound": []any{}, "outbound": []any{}}, result["services"])
+	assert.Equal(t, true, result["openRegistrations"])
+	assert.Equal(t, map[string]any{}, result["metadata"])
+	assert.Equal(t, float64(100), result["usage"].(map[string]any)["localPosts"])
+}
+
+// TestServe_Version20 confirms fields added in 2.1 are left out of 2.0 documents.
+func TestServe_Version20(t *testing.T) {
+
+	recorder, err := serveTest("/nodeinfo/2.0", func(ctx echo.Context) error {
+		return Serve(ctx, testProvider{}, Version20)
+	})
+	require.NoError(t, err)
+
+	result := NodeInfo{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+
+	assert.Equal(t, "2.0", result.Version)
+	assert.Equal(t, Software{Name: "hannibal", Version: "1.0.0"}, result.Software)
+	assert.NotContains(t, recorder.Body.String(), "repository")
+}
+
+// TestServe_Errors confirms unsupported versions and Provider errors.
+func TestServe_Errors(t *testing.T) {
+
+	recorder, err := serveTest("/nodeinfo/1.0", func(ctx echo.Context) error {
+		return Serve(ctx, testProvider{}, "1.0")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	_, err = serveTest("/nodeinfo/2.1", func(ctx echo.Context) error {
+		return Serve(ctx, testProvider{usageErr: errors.New("database is down")}, Version21)
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"crypto/rsa"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal"
+	"github.com/benpate/hannibal/datetime"
+	"github.com/benpate/hannibal/sigs"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/rosetta/mapof"
+	"github.com/labstack/echo/v4"
+)
+
+// ActorProfile is the public information that an Actor publishes in its ActivityPub
+// actor document. The actor's ID and public key come from the Actor itself.
+// https://www.w3.org/TR/activitypub/#actor-objects
+type ActorProfile struct {
+	Type              string    // ActivityStreams actor type (defaults to "Person")
+	Name              string    // Display name
+	PreferredUsername string    // Username, without the "@" or domain (e.g. "alice")
+	Summary           string    // Biography, in HTML
+	URL               string    // URL of the actor's HTML profile page
+	Icon              string    // URL of the actor's avatar image
+	Image             string    // URL of the actor's header/banner image
+	Published         time.Time // When the actor was created
+
+	Inbox       string // URL of the actor's inbox (required)
+	Outbox      string // URL of the actor's outbox (required)
+	Followers   string // URL of the actor's followers collection
+	Following   string // URL of the actor's following collection
+	Liked       string // URL of the actor's liked collection
+	Featured    string // URL of the actor's featured (pinned) collection
+	SharedInbox string // URL of the server's shared inbox
+
+	ManuallyApprovesFollowers bool     // TRUE if Follow requests are reviewed by hand
+	Discoverable              bool     // TRUE if the actor can be listed in directories and suggestions
+	Indexable                 bool     // TRUE if the actor's public posts can be included in search results
+	AlsoKnownAs               []string // Other actor IDs that belong to the same person
+}
+
+// Profile builds this Actor's public ActivityPub actor document from the provided profile.
+// The document includes the security and Mastodon extension terms in its @context, and a
+// "publicKey" block derived from the Actor's private key, which must be an RSA key.
+func (actor *Actor) Profile(profile ActorProfile) (mapof.Any, error) {
+
+	const location = "hannibal.outbox.Actor.Profile"
+
+	if (profile.Inbox == "") || (profile.Outbox == "") {
+		return nil, derp.Internal(location, "Actor profile must include an inbox and outbox", actor.actorID)
+	}
+
+	privateKey, ok := actor.privateKey.(*rsa.PrivateKey)
+
+	if !ok {
+		return nil, derp.Internal(location, "Actor must have an RSA private key to publish its public key", actor.actorID)
+	}
+
+	if profile.Type == "" {
+		profile.Type = vocab.ActorTypePerson
+	}
+
+	result := mapof.Any{
+		vocab.AtContext:                         actorContext(),
+		vocab.PropertyID:                        actor.actorID,
+		vocab.PropertyType:                      profile.Type,
+		vocab.PropertyInbox:                     profile.Inbox,
+		vocab.PropertyOutbox:                    profile.Outbox,
+		vocab.PropertyManuallyApprovesFollowers: profile.ManuallyApprovesFollowers,
+		vocab.PropertyTootDiscoverable:          profile.Discoverable,
+		vocab.PropertyTootIndexable:             profile.Indexable,
+		vocab.PropertyPublicKey: mapof.Any{
+			vocab.PropertyID:           actor.publicKeyID,
+			vocab.PropertyOwner:        actor.actorID,
+			vocab.PropertyPublicKeyPEM: sigs.EncodePublicPEM(privateKey),
+		},
+	}
+
+	// Optional values are only included when they are present
+	setIfPresent(result, vocab.PropertyName, profile.Name)
+	setIfPresent(result, vocab.PropertyPreferredUsername, profile.PreferredUsername)
+	setIfPresent(result, vocab.PropertySummary, profile.Summary)
+	setIfPresent(result, vocab.PropertyURL, profile.URL)
+	setIfPresent(result, vocab.PropertyPublished, datetime.Format(profile.Published))
+	setIfPresent(result, vocab.PropertyFollowers, profile.Followers)
+	setIfPresent(result, vocab.PropertyFollowing, profile.Following)
+	setIfPresent(result, vocab.PropertyLiked, profile.Liked)
+	setIfPresent(result, vocab.PropertyFeatured, profile.Featured)
+
+	if profile.Icon != "" {
+		result[vocab.PropertyIcon] = imageValue(profile.Icon)
+	}
+
+	if profile.Image != "" {
+		result[vocab.PropertyImage] = imageValue(profile.Image)
+	}
+
+	if profile.SharedInbox != "" {
+		result[vocab.PropertyEndpoints] = mapof.Any{
+			vocab.EndpointSharedInbox: profile.SharedInbox,
+		}
+	}
+
+	if len(profile.AlsoKnownAs) > 0 {
+		result[vocab.PropertyAlsoKnownAs] = profile.AlsoKnownAs
+	}
+
+	return result, nil
+}
+
+// ServeProfile writes this Actor's public ActivityPub actor document to the HTTP response.
+// ActivityPub clients receive compact JSON, and everyone else receives indented JSON that
+// is easier to read in a browser.
+func (actor *Actor) ServeProfile(ctx echo.Context, profile ActorProfile) error {
+
+	const location = "hannibal.outbox.Actor.ServeProfile"
+
+	result, err := actor.Profile(profile)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to build actor profile", actor.actorID)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentType, vocab.ContentTypeActivityPub)
+
+	if hannibal.IsActivityPubRequest(ctx.Request()) {
+		return ctx.JSON(http.StatusOK, result)
+	}
+
+	return ctx.JSONPretty(http.StatusOK, result, "    ")
+}
+
+// actorContext returns the JSON-LD @context for an actor document: ActivityStreams, the
+// security vocabulary (for "publicKey"), and the Mastodon extension terms.
+func actorContext() []any {
+
+	extensions := maps.Clone(vocab.ContextTypeToot)
+	extensions[vocab.PropertyManuallyApprovesFollowers] = "as:" + vocab.PropertyManuallyApprovesFollowers
+	extensions[vocab.PropertyAlsoKnownAs] = map[string]any{
+		"@id":   "as:" + vocab.PropertyAlsoKnownAs,
+		"@type": "@id",
+	}
+
+	return []any{
+		vocab.ContextTypeActivityStreams,
+		vocab.ContextTypeSecurity,
+		extensions,
+	}
+}
+
+// imageValue returns an ActivityStreams Image object for the provided URL
+func imageValue(url string) mapof.Any {
+	return mapof.Any{
+		vocab.PropertyType: vocab.ObjectTypeImage,
+		vocab.PropertyURL:  url,
+	}
+}
+
+// setIfPresent sets a property only if its value is not empty
+func setIfPresent(result mapof.Any, name string, value string) {
+	if value != "" {
+		result[name] = value
+	}
+}
@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benpate/hannibal/sigs"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProfileActor returns an Actor with a fresh RSA key, and the key itself
+func newProfileActor(t *testing.T) (Actor, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return NewActor("https://example.com/users/alice", privateKey), privateKey
+}
+
+// testProfile returns a complete ActorProfile for alice
+func testProfile() ActorProfile {
+	return ActorProfile{
+		Name:                      "Alice",
+		PreferredUsername:         "alice",
+		Summary:                   "<p>Hello</p>",
+		URL:                       "https://example.com/@alice",
+		Icon:                      "https://example.com/alice.png",
+		Published:                 time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Inbox:                     "https://example.com/users/alice/inbox",
+		Outbox:                    "https://example.com/users/alice/outbox",
+		Followers:                 "https://example.com/users/alice/followers",
+		Following:                 "https://example.com/users/alice/following",
+		SharedInbox:               "https://example.com/inbox",
+		ManuallyApprovesFollowers: true,
+		Discoverable:              true,
+		AlsoKnownAs:               []string{"https://old.example/users/alice"},
+	}
+}
+
+// TestActor_Profile confirms the actor document includes every profile value and a matching public key.
+func TestActor_Profile(t *testing.T) {
+
+	actor, privateKey := newProfileActor(t)
+
+	result, err := actor.Profile(testProfile())
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://example.com/users/alice", result.GetString(vocab.PropertyID))
+	assert.Equal(t, vocab.ActorTypePerson, result.GetString(vocab.PropertyType))
+	assert.Equal(t, "alice", result.GetString(vocab.PropertyPreferredUsername))
+	assert.Equal(t, "2024-01-01T00:00:00Z", result.GetString(vocab.PropertyPublished))
+	assert.Equal(t, "https://example.com/users/alice/inbox", result.GetString(vocab.PropertyInbox))
+	assert.Equal(t, "https://example.com/inbox", result.GetMap(vocab.PropertyEndpoints).GetString(vocab.EndpointSharedInbox))
+	assert.Equal(t, "https://example.com/alice.png", result.GetMap(vocab.PropertyIcon).GetString(vocab.PropertyURL))
+	assert.True(t, result.GetBool(vocab.PropertyManuallyApprovesFollowers))
+	assert.True(t, result.GetBool(vocab.PropertyTootDiscoverable))
+	assert.False(t, result.GetBool(vocab.PropertyTootIndexable))
+	assert.Equal(t, []string{"https://old.example/users/alice"}, result[vocab.PropertyAlsoKnownAs])
+
+	// Empty values are left out
+	assert.NotContains(t, result, vocab.PropertyImage)
+	assert.NotContains(t, result, vocab.PropertyLiked)
+
+	// The public key matches the actor's private key
+	publicKey := result.GetMap(vocab.PropertyPublicKey)
+	assert.Equal(t, "https://example.com/users/alice#main-key", publicKey.GetString(vocab.PropertyID))
+	assert.Equal(t, "https://example.com/users/alice", publicKey.GetString(vocab.PropertyOwner))
+	assert.Equal(t, sigs.EncodePublicPEM(privateKey), publicKey.GetString(vocab.PropertyPublicKeyPEM))
+
+	// The context defines the security and extension terms
+	context := result[vocab.AtContext].([]any)
+	require.Len(t, context, 3)
+	assert.Equal(t, vocab.ContextTypeActivityStreams, context[0])
+	assert.Equal(t, vocab.ContextTypeSecurity, context[1])
+
+	extensions := context[2].(map[string]any)
+	assert.Equal(t, "as:manuallyApprovesFollowers", extensions["manuallyApprovesFollowers"])
+	assert.Equal(t, "toot:discoverable", extensions["discoverable"])
+	assert.Contains(t, extensions, "alsoKnownAs")
+
+	// The shared vocabulary is not modified
+	assert.NotContains(t, vocab.ContextTypeToot, "alsoKnownAs")
+}
+
+// TestActor_Profile_Errors confirms missing inboxes and non-RSA keys are rejected.
+func TestActor_Profile_Errors(t *testing.T) {
+
+	actor, _ := newProfileActor(t)
+
+	profile := testProfile()
+	profile.Outbox = ""
+	_, err := actor.Profile(profile)
+	require.Error(t, err)
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ed25519Actor := NewActor("https://example.com/users/alice", ed25519Key)
+	_, err = ed25519Actor.Profile(testProfile())
+	require.Error(t, err)
+}
+
+// TestActor_ServeProfile confirms ActivityPub clients get compact JSON and browsers get indented JSON.
+func TestActor_ServeProfile(t *testing.T) {
+
+	actor, _ := newProfileActor(t)
+
+	serve := func(accept string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/users/alice", nil)
+		request.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		require.NoError(t, actor.ServeProfile(echo.New().NewContext(request, recorder), testProfile()))
+		return recorder
+	}
+
+	activityPub := serve(vocab.ContentTypeActivityPub)
+	assert.Equal(t, http.StatusOK, activityPub.Code)
+	assert.Equal(t, vocab.ContentTypeActivityPub, activityPub.Header().Get("Content-Type"))
+	assert.False(t, strings.Contains(activityPub.Body.String(), "\n    "))
+
+	browser := serve("text/html")
+	assert.True(t, strings.Contains(browser.Body.String(), "\n    "))
+
+	result := map[string]any{}
+	require.NoError(t, json.Unmarshal(browser.Body.Bytes(), &result))
+	assert.Equal(t, "https://example.com/users/alice", result["id"])
+}
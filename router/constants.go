@@ -0,0 +1,11 @@
+package router
+
+import "time"
+
+// InboxHandleActivity is the name of the task that routes a received
+// ActivityPub activity to its handler, in the background.
+const InboxHandleActivity = "Inbox:HandleActivity"
+
+// inboxRetryAfter is how long inbox endpoints ask peers to wait before retrying an activity
+// that could not be handled yet, when nothing more specific is known.
+const inboxRetryAfter = time.Minute
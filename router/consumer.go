@@ -0,0 +1,92 @@
+package router
+
+import (
+	"context"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/rosetta/convert"
+	"github.com/benpate/turbine/queue"
+)
+
+// ContextFunc rebuilds the context value for a RouteHandler when an activity is handled
+// in the background. It receives the recipientID that was passed to ReceiveAndQueue.
+type ContextFunc[T any] func(recipientID string) (T, error)
+
+// Consumer returns a turbine queue.Consumer that handles the activities queued by ReceiveAndQueue.
+// Activities are loaded with the provided client, and are routed through this Router (including
+// its middleware) exactly as ReceiveAndHandle would.
+//
+// Like sender.Consumer, errors that can be retried (such as a remote server being unavailable)
+// return queue.Error so that the queue tries again, "429 Too Many Requests" errors are requeued
+// after their delay, and client errors fail permanently. Duplicates reported by the Dedup
+// middleware are treated as successes.
+func (router *Router[T]) Consumer(contextFunc ContextFunc[T], client streams.Client) queue.Consumer {
+
+	return func(name string, args map[string]any) queue.Result {
+
+		// All other task names are left for other consumers.
+		if name != InboxHandleActivity {
+			return queue.Ignored()
+		}
+
+		return router.handleQueued(contextFunc, client, args)
+	}
+}
+
+// handleQueued rebuilds a queued activity and its context, then routes it to the appropriate handler
+func (router *Router[T]) handleQueued(contextFunc ContextFunc[T], client streams.Client, args map[string]any) queue.Result {
+
+	const location = "hannibal.router.Consumer"
+
+	// Collect arguments
+	recipientID := convert.String(args["recipient"])
+	activityMap := convert.MapOfAny(args["activity"])
+
+	if len(activityMap) == 0 {
+		return queue.Failure(derp.BadRequest(location, "Queued task does not include an activity", args))
+	}
+
+	activity := streams.NewDocument(map[string]any(activityMap), streams.WithClient(client))
+
+	// Queued activities are handled for the recipient that they were queued for
+	if recipientID != "" {
+		activity.WithOptions(withRecipientID(context.Background(), recipientID))
+	}
+
+	// Rebuild the context for this activity
+	context, err := contextFunc(recipientID)
+
+	if err != nil {
+		return queueResult(derp.Wrap(err, location, "Unable to build context for queued activity", "recipient: "+recipientID))
+	}
+
+	// Route the activity to the appropriate handler
+	if err := router.Handle(context, activity); err != nil {
+		return queueResult(derp.Wrap(err, location, "Unable to handle queued activity", activity.ID()))
+	}
+
+	return queue.Success()
+}
+
+// queueResult converts a handler error into a queue.Result, using the same retry rules as sender.Consumer
+func queueResult(err error) queue.Result {
+
+	// Duplicates have already been handled
+	if IsDuplicateError(err) {
+		return queue.Success()
+	}
+
+	// Special handling for HTTP 429 (Too Many Requests) error
+	if tooManyRequests, retryDuration := derp.IsTooManyRequests(err); tooManyRequests {
+		return queue.Requeue(retryDuration)
+	}
+
+	// Client errors cannot be retried
+	if derp.IsClientError(err) {
+		return queue.Failure(err)
+	}
+
+	// Otherwise, it is a server error that can be retried by the standard queue mechanism.
+	return queue.Error(err)
+}
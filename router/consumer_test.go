@@ -0,0 +1,144 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/clients"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/validator"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/turbine/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRecordingQueue builds a queue that records each published task, so that tests can
+// pass it to a Consumer themselves
+func newRecordingQueue() (*queue.Queue, *[]queue.Task) {
+
+	tasks := []queue.Task{}
+
+	q := queue.New(
+		queue.WithPreProcessor(func(task *queue.Task) error {
+			tasks = append(tasks, *task)
+			return nil
+		}),
+		queue.WithBufferSize(16),
+	)
+
+	return q, &tasks
+}
+
+// recipientContext returns a ContextFunc that records the recipient in a capture context
+func recipientContext(recipientID string) (*capture, error) {
+	return &capture{hit: "recipient:" + recipientID}, nil
+}
+
+// TestReceiveAndQueue confirms a valid request is queued (not handled), and that the
+// Consumer hands it to the Router with a rebuilt context.
+func TestReceiveAndQueue(t *testing.T) {
+
+	var handled *capture
+
+	router := New[*capture]()
+	router.Add(vocab.ActivityTypeFollow, vocab.Any, func(context *capture, activity streams.Document) error {
+		assert.Equal(t, "https://example.com/activities/1", activity.ID())
+		handled = context
+		return nil
+	})
+
+	q, tasks := newRecordingQueue()
+	client := streams.NewDefaultClient()
+
+	err := router.ReceiveAndQueue(q, "https://example.com/users/bob", newActivityRequest(followActivityJSON), client,
+		WithValidators(stubValidator{validator.ResultValid}))
+
+	require.NoError(t, err)
+	require.Len(t, *tasks, 1)
+	assert.Equal(t, InboxHandleActivity, (*tasks)[0].Name)
+	assert.Nil(t, handled, "the handler must not run inside the HTTP request")
+
+	// Process the queued task
+	result := router.Consumer(recipientContext, client)((*tasks)[0].Name, (*tasks)[0].Arguments)
+
+	assert.Equal(t, queue.ResultStatusSuccess, result.Status)
+	require.NotNil(t, handled)
+	assert.Equal(t, "recipient:https://example.com/users/bob", handled.hit)
+}
+
+// TestReceiveAndQueue_Invalid confirms requests that fail validation are never queued.
+func TestReceiveAndQueue_Invalid(t *testing.T) {
+
+	router := New[*capture]()
+	q, tasks := newRecordingQueue()
+
+	err := router.ReceiveAndQueue(q, "", newActivityRequest(followActivityJSON), streams.NewDefaultClient(),
+		WithValidators(stubValidator{validator.ResultInvalid}))
+
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, derp.ErrorCode(err))
+	assert.Empty(t, *tasks)
+}
+
+// TestConsumer_Results confirms handler errors map onto queue results the same way as sender.Consumer.
+func TestConsumer_Results(t *testing.T) {
+
+	check := func(description string, handlerErr error, expected queue.ResultStatus) {
+
+		router := New[*capture]()
+		router.Use(Dedup[*capture](NewMemorySeenStore(), time.Hour))
+		router.Add(vocab.Any, vocab.Any, func(context *capture, activity streams.Document) error {
+			return handlerErr
+		})
+
+		consumer := router.Consumer(recipientContext, streams.NewDefaultClient())
+		args := map[string]any{
+			"recipient": "",
+			"activity":  map[string]any{vocab.PropertyType: vocab.ActivityTypeLike, vocab.PropertyObject: map[string]any{}},
+		}
+
+		assert.Equal(t, expected, consumer(InboxHandleActivity, args).Status, description)
+	}
+
+	check("success", nil, queue.ResultStatusSuccess)
+	check("server error", errors.New("database is down"), queue.ResultStatusError)
+	check("client error", derp.BadRequest("test", "bad activity"), queue.ResultStatusFailure)
+	check("rate limited", clients.RateLimitError{Host: "remote.example", RetryAfter: time.Minute}, queue.ResultStatusRequeue)
+}
+
+// TestConsumer_Duplicate confirms duplicates are acknowledged rather than retried.
+func TestConsumer_Duplicate(t *testing.T) {
+
+	router := New[*capture]()
+	router.Use(Dedup[*capture](NewMemorySeenStore(), time.Hour))
+	router.Add(vocab.Any, vocab.Any, handler("handled"))
+
+	consumer := router.Consumer(recipientContext, streams.NewDefaultClient())
+	args := map[string]any{
+		"activity": map[string]any{vocab.PropertyID: "https://example.com/likes/1", vocab.PropertyType: vocab.ActivityTypeLike, vocab.PropertyObject: map[string]any{}},
+	}
+
+	assert.Equal(t, queue.ResultStatusSuccess, consumer(InboxHandleActivity, args).Status)
+	assert.Equal(t, queue.ResultStatusSuccess, consumer(InboxHandleActivity, args).Status)
+}
+
+// TestConsumer_Other confirms other tasks are ignored, and malformed or unroutable tasks fail.
+func TestConsumer_Other(t *testing.T) {
+
+	router := New[*capture]()
+	consumer := router.Consumer(recipientContext, streams.NewDefaultClient())
+
+	assert.Equal(t, queue.ResultStatusIgnored, consumer("Outbox:SendToAllRecipients", map[string]any{}).Status)
+	assert.Equal(t, queue.ResultStatusFailure, consumer(InboxHandleActivity, map[string]any{}).Status)
+
+	failingContext := func(string) (*capture, error) {
+		return nil, derp.NotFound("test", "recipient does not exist")
+	}
+
+	args := map[string]any{"activity": map[string]any{vocab.PropertyType: vocab.ActivityTypeLike}}
+	assert.Equal(t, queue.ResultStatusFailure, router.Consumer(failingContext, streams.NewDefaultClient())(InboxHandleActivity, args).Status)
+}
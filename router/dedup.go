@@ -0,0 +1,111 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/rs/zerolog/log"
+)
+
+// dedupInProgressTTL is the longest time that an activity is marked as in progress. If a
+// server stops while handling an activity, retries are accepted again after this time.
+const dedupInProgressTTL = 10 * time.Minute
+
+// dedupDefaultTTL is how long activities are remembered when Dedup is given a TTL
+// that is zero or negative
+const dedupDefaultTTL = 24 * time.Hour
+
+// Dedup is a Middleware that drops activities that have already been received, such as
+// deliveries that a peer retries, or activities that several relays forward. Activities
+// are identified by their actor and ID, or by a hash of their contents if they have no ID,
+// and are remembered in the SeenStore for the duration of the TTL.
+//
+// Duplicates are never passed to later handlers. Instead, Dedup returns a DuplicateError,
+// whose ErrorCode is HTTP 202 (Accepted). Copies that arrive while the first one is still
+// being handled return an InProgressError (HTTP 503) instead, so that the peer tries again
+// later. If a handler fails, the activity is forgotten, so that the peer's next retry is
+// handled normally. If the SeenStore fails, the activity is handled anyway. A TTL that is
+// zero or negative remembers activities for 24 hours.
+//
+// Callers of Handle and ReceiveAndHandle should check for duplicates with IsDuplicateError,
+// and treat them as successes. HTTPHandler, EchoHandler, SharedInbox, and Consumer already do.
+func Dedup[T any](store SeenStore, ttl time.Duration) Middleware[T] {
+
+	const location = "hannibal.router.Dedup"
+
+	if ttl <= 0 {
+		ttl = dedupDefaultTTL
+	}
+
+	return func(next RouteHandler[T]) RouteHandler[T] {
+		return func(context T, activity streams.Document) error {
+
+			key := dedupKey(activity)
+
+			status, err := store.MarkInProgress(key, min(ttl, dedupInProgressTTL))
+
+			if err != nil {
+				derp.Report(derp.Wrap(err, location, "Unable to check for duplicate activity", key))
+				return next(context, activity)
+			}
+
+			switch status {
+
+			case SeenStatusSeen:
+				log.Trace().Str("key", key).Msg("Hannibal Router: duplicate activity ignored")
+				return DuplicateError{Key: key}
+
+			case SeenStatusInProgress:
+				log.Trace().Str("key", key).Msg("Hannibal Router: activity is already being handled")
+				return InProgressError{Key: key}
+			}
+
+			if err := next(context, activity); err != nil {
+
+				if forgetErr := store.Forget(key); forgetErr != nil {
+					derp.Report(derp.Wrap(forgetErr, location, "Unable to forget failed activity", key))
+				}
+
+				return err
+			}
+
+			if _, err := store.MarkSeen(key, ttl); err != nil {
+				derp.Report(derp.Wrap(err, location, "Unable to mark activity as seen", key))
+			}
+
+			return nil
+		}
+	}
+}
+
+// dedupKey returns the key that identifies an activity: its ID, or a hash of its
+// contents if it has no ID. Activities that a SharedInbox is handling for a local
+// recipient are keyed separately for each recipient.
+func dedupKey(activity streams.Document) string {
+
+	if recipientID := RecipientID(activity); recipientID != "" {
+		return "recipient:" + recipientID + " " + activityKey(activity)
+	}
+
+	return activityKey(activity)
+}
+
+// activityKey returns the actor and ID of an activity, or a hash of its contents if it has
+// no ID. The actor is included so that one server cannot suppress another server's activities
+// by sending something else with the same ID first.
+func activityKey(activity streams.Document) string {
+
+	if activityID := activity.ID(); activityID != "" {
+		return "actor:" + activity.ActorID() + " id:" + activityID
+	}
+
+	// json.Marshal sorts map keys, so equal activities produce equal hashes
+	data, _ := json.Marshal(activity.Value())
+	hash := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
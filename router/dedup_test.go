@@ -0,0 +1,219 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/validator"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingSeenStore is a SeenStore that is always unavailable
+type failingSeenStore struct{}
+
+func (failingSeenStore) MarkInProgress(string, time.Duration) (SeenStatus, error) {
+	return SeenStatusNew, errors.New("database is down")
+}
+
+func (failingSeenStore) MarkSeen(string, time.Duration) (bool, error) {
+	return false, errors.New("database is down")
+}
+
+func (failingSeenStore) Forget(string) error {
+	return errors.New("database is down")
+}
+
+// ttlSeenStore is a MemorySeenStore that records the TTL of the last activity marked as seen
+type ttlSeenStore struct {
+	*MemorySeenStore
+	ttl time.Duration
+}
+
+func (store *ttlSeenStore) MarkSeen(key string, ttl time.Duration) (bool, error) {
+	store.ttl = ttl
+	return store.MemorySeenStore.MarkSeen(key, ttl)
+}
+
+// newDedupRouter returns a Router that counts the activities that reach its handler
+func newDedupRouter(store SeenStore, handlerErr *error) (*Router[*trace], *int) {
+
+	count := 0
+	router := New[*trace]()
+	router.Use(Dedup[*trace](store, time.Hour))
+	router.Add(vocab.Any, vocab.Any, func(context *trace, activity streams.Document) error {
+		count++
+		return *handlerErr
+	})
+
+	return &router, &count
+}
+
+// TestDedup confirms activities with the same ID reach the handler only once.
+func TestDedup(t *testing.T) {
+
+	var handlerErr error
+	router, count := newDedupRouter(NewMemorySeenStore(), &handlerErr)
+
+	like := func(id string) streams.Document {
+		return streams.NewDocument(map[string]any{
+			vocab.PropertyID:     id,
+			vocab.PropertyType:   vocab.ActivityTypeLike,
+			vocab.PropertyActor:  "https://example.com/users/alice",
+			vocab.PropertyObject: map[string]any{vocab.PropertyType: vocab.ObjectTypeNote},
+		})
+	}
+
+	require.NoError(t, router.Handle(&trace{}, like("https://example.com/likes/1")))
+
+	err := router.Handle(&trace{}, like("https://example.com/likes/1"))
+	require.Error(t, err)
+	assert.True(t, IsDuplicateError(err))
+	assert.Equal(t, http.StatusAccepted, derp.ErrorCode(err))
+
+	require.NoError(t, router.Handle(&trace{}, like("https://example.com/likes/2")))
+	assert.Equal(t, 2, *count)
+}
+
+// TestDedup_DifferentActors confirms an activity ID only matches activities from the same actor,
+// so one server cannot suppress another's activities by reusing their IDs.
+func TestDedup_DifferentActors(t *testing.T) {
+
+	var handlerErr error
+	router, count := newDedupRouter(NewMemorySeenStore(), &handlerErr)
+
+	like := func(actorID string) streams.Document {
+		return streams.NewDocument(map[string]any{
+			vocab.PropertyID:     "https://example.com/likes/1",
+			vocab.PropertyType:   vocab.ActivityTypeLike,
+			vocab.PropertyActor:  actorID,
+			vocab.PropertyObject: "https://example.com/notes/1",
+		})
+	}
+
+	require.NoError(t, router.Handle(&trace{}, like("https://attacker.example/users/mallory")))
+	require.NoError(t, router.Handle(&trace{}, like("https://example.com/users/alice")))
+	assert.True(t, IsDuplicateError(router.Handle(&trace{}, like("https://example.com/users/alice"))))
+	assert.Equal(t, 2, *count)
+}
+
+// TestDedup_InProgress confirms a copy that arrives while the first one is still being handled
+// is asked to retry (instead of being accepted), so it is not lost if the first attempt fails.
+func TestDedup_InProgress(t *testing.T) {
+
+	started := make(chan struct{})
+	release := make(chan error)
+	count := 0
+
+	router := New[*trace]()
+	router.Use(Dedup[*trace](NewMemorySeenStore(), time.Hour))
+	router.Add(vocab.Any, vocab.Any, func(context *trace, activity streams.Document) error {
+		count++
+		if count == 1 {
+			started <- struct{}{}
+			return <-release
+		}
+		return nil
+	})
+
+	first := make(chan error)
+	go func() {
+		first <- router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote))
+	}()
+
+	<-started
+
+	// The concurrent copy is not accepted, so that the peer retries it later
+	err := router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote))
+	assert.True(t, IsInProgressError(err))
+	assert.False(t, IsDuplicateError(err))
+	assert.Equal(t, http.StatusServiceUnavailable, derp.ErrorCode(err))
+
+	// The first attempt fails, so the retry is handled normally
+	release <- errors.New("handler failed")
+	require.Error(t, <-first)
+
+	require.NoError(t, router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)))
+	assert.True(t, IsDuplicateError(router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote))))
+	assert.Equal(t, 2, count)
+}
+
+// TestDedup_ContentHash confirms activities without an ID are identified by their contents.
+func TestDedup_ContentHash(t *testing.T) {
+
+	var handlerErr error
+	router, count := newDedupRouter(NewMemorySeenStore(), &handlerErr)
+
+	require.NoError(t, router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)))
+	assert.True(t, IsDuplicateError(router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote))))
+	require.NoError(t, router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeImage)))
+
+	assert.Equal(t, 2, *count)
+}
+
+// TestDedup_HandlerError confirms activities that fail are handled again when the peer retries.
+func TestDedup_HandlerError(t *testing.T) {
+
+	handlerErr := errors.New("handler failed")
+	router, count := newDedupRouter(NewMemorySeenStore(), &handlerErr)
+
+	require.ErrorIs(t, router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)), handlerErr)
+
+	handlerErr = nil
+	require.NoError(t, router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)))
+	assert.Equal(t, 2, *count)
+}
+
+// TestDedup_StoreError confirms activities are still handled when the SeenStore is unavailable.
+func TestDedup_StoreError(t *testing.T) {
+
+	var handlerErr error
+	router, count := newDedupRouter(failingSeenStore{}, &handlerErr)
+
+	require.NoError(t, router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)))
+	require.NoError(t, router.Handle(&trace{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)))
+	assert.Equal(t, 2, *count)
+}
+
+// TestDedup_ReceiveAndHandle confirms duplicates keep their 202 status through ReceiveAndHandle.
+func TestDedup_ReceiveAndHandle(t *testing.T) {
+
+	var handlerErr error
+	router, count := newDedupRouter(NewMemorySeenStore(), &handlerErr)
+	client := streams.NewDefaultClient()
+	options := WithValidators(stubValidator{validator.ResultValid})
+
+	require.NoError(t, router.ReceiveAndHandle(&trace{}, newActivityRequest(followActivityJSON), client, options))
+
+	err := router.ReceiveAndHandle(&trace{}, newActivityRequest(followActivityJSON), client, options)
+	assert.True(t, IsDuplicateError(err))
+	assert.Equal(t, http.StatusAccepted, derp.ErrorCode(err))
+	assert.Equal(t, 1, *count)
+}
+
+// TestDedup_ZeroTTL confirms a TTL of zero or less still remembers activities, for the default time.
+func TestDedup_ZeroTTL(t *testing.T) {
+
+	for _, ttl := range []time.Duration{0, -time.Hour} {
+
+		store := &ttlSeenStore{MemorySeenStore: NewMemorySeenStore()}
+		router := New[*trace]()
+		router.Use(Dedup[*trace](store, ttl))
+		router.Add(vocab.Any, vocab.Any, func(*trace, streams.Document) error { return nil })
+
+		like := streams.NewDocument(map[string]any{
+			vocab.PropertyID:    "https://example.com/likes/1",
+			vocab.PropertyType:  vocab.ActivityTypeLike,
+			vocab.PropertyActor: "https://example.com/users/alice",
+		})
+
+		require.NoError(t, router.Handle(&trace{}, like))
+		assert.True(t, IsDuplicateError(router.Handle(&trace{}, like)))
+		assert.Equal(t, dedupDefaultTTL, store.ttl)
+	}
+}
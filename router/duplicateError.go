@@ -0,0 +1,29 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DuplicateError is returned by the Dedup middleware when an activity has already been
+// received. The activity is not passed to any handlers. Its ErrorCode is HTTP 202 (Accepted)
+// so that the peer sees a successful delivery and stops retrying.
+type DuplicateError struct {
+	Key string // Key that identified the duplicate activity (its ID, or a hash of its contents)
+}
+
+// Error implements the error interface
+func (err DuplicateError) Error() string {
+	return "hannibal.router.Dedup: activity has already been received: " + err.Key
+}
+
+// ErrorCode returns HTTP 202 (Accepted) because the activity was already accepted earlier
+func (err DuplicateError) ErrorCode() int {
+	return http.StatusAccepted
+}
+
+// IsDuplicateError returns TRUE if the provided error (or any error that it wraps) is a DuplicateError
+func IsDuplicateError(err error) bool {
+	var duplicateError DuplicateError
+	return errors.As(err, &duplicateError)
+}
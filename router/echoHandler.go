@@ -0,0 +1,32 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/benpate/hannibal/streams"
+	"github.com/labstack/echo/v4"
+)
+
+// EchoContextFunc builds the context value that handlers receive for a single inbox
+// request, for instance by looking up the local actor named in the route's parameters.
+type EchoContextFunc[T any] func(ctx echo.Context) (T, error)
+
+// EchoHandler returns an echo.HandlerFunc for an inbox endpoint. It works the same way
+// as HTTPHandler, and writes the same status codes and headers.
+func (router *Router[T]) EchoHandler(contextFunc EchoContextFunc[T], client streams.Client, options ...Option) echo.HandlerFunc {
+
+	return func(ctx echo.Context) error {
+
+		response := router.serveInbox(ctx.Response(), ctx.Request(), func() (T, error) {
+			return contextFunc(ctx)
+		}, client, options...)
+
+		writeInboxHeaders(ctx.Response().Header(), response)
+
+		if response.statusCode == http.StatusAccepted {
+			return ctx.NoContent(response.statusCode)
+		}
+
+		return ctx.String(response.statusCode, response.message)
+	}
+}
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/validator"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEchoHandler confirms the echo adapter builds the context from route parameters,
+// and writes the same status codes as the HTTP adapter.
+func TestEchoHandler(t *testing.T) {
+
+	router := newInboxRouter(nil)
+
+	var username string
+	contextFunc := func(ctx echo.Context) (*capture, error) {
+		username = ctx.Param("username")
+		if username != "alice" {
+			return nil, derp.NotFound("test", "unknown user", username)
+		}
+		return &capture{}, nil
+	}
+
+	e := echo.New()
+	e.Any("/users/:username/inbox", router.EchoHandler(contextFunc, streams.NewDefaultClient(), WithValidators(stubValidator{validator.ResultValid})))
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		request := newActivityRequest(body)
+		request.Method = method
+		request.URL.Path = path
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := serve(http.MethodPost, "/users/alice/inbox", followActivityJSON)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Equal(t, "alice", username)
+	assert.Empty(t, recorder.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/users/bob/inbox", followActivityJSON).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/users/alice/inbox", `[`).Code)
+
+	recorder = serve(http.MethodGet, "/users/alice/inbox", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	assert.Equal(t, http.MethodPost, recorder.Header().Get("Allow"))
+}
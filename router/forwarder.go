@@ -0,0 +1,197 @@
+package router
+
+import (
+	"slices"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/rs/zerolog/log"
+)
+
+// OwnerFunc returns the ID of the local actor that owns the object or collection with
+// the provided ID, or an empty string if it is not owned by this server. An actor owns
+// itself, so OwnerFunc should return an actor's own ID when asked about that actor.
+type OwnerFunc func(id string) string
+
+// ForwardSender re-delivers the original body of an inbound activity to every member of
+// the provided (local) collections, signed by the local actor that owns them.
+// sender.Sender satisfies this interface.
+type ForwardSender interface {
+	Forward(actorID string, body []byte, collections ...string) error
+}
+
+// Forwarder implements "Forwarding from an Inbox" (ActivityPub section 7.1.2). When an
+// inbound activity is addressed to a local collection (such as an actor's followers) and
+// references a local object (such as a reply to a local post) then the original body is
+// handed to the ForwardSender, so that the whole thread reaches every follower.
+//
+// The body is forwarded exactly as it was received, so activities that carry a Linked Data
+// Signature or an Object Integrity Proof remain verifiable by the final recipients.
+type Forwarder struct {
+	sender   ForwardSender
+	owner    OwnerFunc
+	seen     SeenStore     // Prevents the same activity from being forwarded twice (in memory by default)
+	ttl      time.Duration // How long to remember forwarded activities
+	maxDepth int           // How many levels of embedded objects to search for local objects
+}
+
+// NewForwarder returns a fully initialized Forwarder
+func NewForwarder(sender ForwardSender, owner OwnerFunc, options ...ForwarderOption) Forwarder {
+
+	result := Forwarder{
+		sender:   sender,
+		owner:    owner,
+		seen:     NewMemorySeenStore(),
+		ttl:      24 * time.Hour,
+		maxDepth: 3,
+	}
+
+	for _, option := range options {
+		option(&result)
+	}
+
+	return result
+}
+
+// Collections returns the local collections that an activity should be forwarded to, grouped
+// by the local actor that owns them. The result is empty if the activity is not addressed to
+// any local collections, or if it does not reference any local objects. This never loads
+// documents from the network; only the activity and the objects embedded within it are searched.
+func (forwarder Forwarder) Collections(activity streams.Document) map[string][]string {
+
+	result := make(map[string][]string)
+
+	// Find local collections in the activity's addressees
+	addressees := activity.Recipients()
+
+	for audience := range activity.Get(vocab.PropertyAudience).RangeIDs() {
+		addressees = append(addressees, audience)
+	}
+
+	for _, addressee := range addressees {
+
+		owner := forwarder.owner(addressee)
+
+		// Skip remote addressees, and local actors (who are not collections)
+		if (owner == "") || (owner == addressee) {
+			continue
+		}
+
+		// Skip duplicates
+		if slices.Contains(result[owner], addressee) {
+			continue
+		}
+
+		result[owner] = append(result[owner], addressee)
+	}
+
+	// RULE: Only forward activities that reference local objects
+	if (len(result) == 0) || !forwarder.referencesLocal(activity, forwarder.maxDepth) {
+		return map[string][]string{}
+	}
+
+	return result
+}
+
+// Forward hands the original body of an inbound activity to the ForwardSender, if the activity
+// is addressed to local collections and references local objects. Activities that have already
+// been forwarded are skipped, so retried deliveries are not forwarded again.
+func (forwarder Forwarder) Forward(activity streams.Document, body []byte) error {
+
+	const location = "hannibal.router.Forwarder.Forward"
+
+	collections := forwarder.Collections(activity)
+
+	if len(collections) == 0 {
+		return nil
+	}
+
+	// RULE: Only forward activities the first time they are received
+	if forwarder.seen != nil {
+
+		// Forwarding does not depend on the recipient, so the key ignores it
+		key := "forward:" + activityKey(activity)
+		alreadySeen, err := forwarder.seen.MarkSeen(key, forwarder.ttl)
+
+		if err != nil {
+			return derp.Wrap(err, location, "Unable to check for previously forwarded activity", key)
+		}
+
+		if alreadySeen {
+			log.Trace().Str("key", key).Msg("Hannibal Router: activity has already been forwarded")
+			return nil
+		}
+	}
+
+	// Forward the activity on behalf of each local actor
+	for owner, ownerCollections := range collections {
+		if err := forwarder.sender.Forward(owner, body, ownerCollections...); err != nil {
+			forwarder.forget(activity)
+			return derp.Wrap(err, location, "Unable to forward activity", activity.ID(), owner)
+		}
+	}
+
+	return nil
+}
+
+// forget removes a forwarded activity from the SeenStore, so that it can be forwarded
+// again when the peer retries. Errors are reported, because the original error wins.
+func (forwarder Forwarder) forget(activity streams.Document) {
+
+	const location = "hannibal.router.Forwarder.forget"
+
+	if forwarder.seen == nil {
+		return
+	}
+
+	key := "forward:" + activityKey(activity)
+
+	if err := forwarder.seen.Forget(key); err != nil {
+		derp.Report(derp.Wrap(err, location, "Unable to forget forwarded activity", key))
+	}
+}
+
+// referencesLocal returns TRUE if the inReplyTo, object, target, or tag properties of a
+// document point to a local object. Embedded objects are searched recursively, up to the
+// provided depth.
+func (forwarder Forwarder) referencesLocal(document streams.Document, depth int) bool {
+
+	if depth <= 0 {
+		return false
+	}
+
+	properties := []string{
+		vocab.PropertyInReplyTo,
+		vocab.PropertyObject,
+		vocab.PropertyTarget,
+		vocab.PropertyTag,
+	}
+
+	for _, property := range properties {
+
+		// Only inspect values that are already present, so that links are not loaded from the network
+		for item := range document.Get(property).Range() {
+
+			switch {
+
+			case item.IsString():
+				if forwarder.owner(item.String()) != "" {
+					return true
+				}
+
+			case item.IsMap():
+				if id := item.ID(); (id != "") && (forwarder.owner(id) != "") {
+					return true
+				}
+
+				if forwarder.referencesLocal(item, depth-1) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,27 @@
+package router
+
+import "time"
+
+// ForwarderOption is a function that modifies a Forwarder
+type ForwarderOption func(*Forwarder)
+
+// ForwarderSeenStore remembers forwarded activities in the provided SeenStore for the
+// duration of the TTL, so that each activity is only forwarded the first time it is received.
+// Forwarders use a MemorySeenStore by default. Use a shared store when several processes
+// receive activities for the same actors.
+func ForwarderSeenStore(store SeenStore, ttl time.Duration) ForwarderOption {
+	return func(forwarder *Forwarder) {
+		forwarder.seen = store
+		forwarder.ttl = ttl
+	}
+}
+
+// ForwarderMaxDepth sets how many levels of embedded objects are searched for references
+// to local objects. Values less than one are ignored.
+func ForwarderMaxDepth(depth int) ForwarderOption {
+	return func(forwarder *Forwarder) {
+		if depth > 0 {
+			forwarder.maxDepth = depth
+		}
+	}
+}
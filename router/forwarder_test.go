@@ -0,0 +1,199 @@
+package router
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forwardCall is a single call to a recordingForwardSender
+type forwardCall struct {
+	actorID     string
+	body        string
+	collections []string
+}
+
+// recordingForwardSender is a ForwardSender that records every call
+type recordingForwardSender struct {
+	calls []forwardCall
+	err   error
+}
+
+func (sender *recordingForwardSender) Forward(actorID string, body []byte, collections ...string) error {
+	sender.calls = append(sender.calls, forwardCall{actorID: actorID, body: string(body), collections: collections})
+	return sender.err
+}
+
+// localOwner is an OwnerFunc where everything on https://local.example belongs to alice
+func localOwner(id string) string {
+	if strings.HasPrefix(id, "https://local.example/") {
+		return "https://local.example/users/alice"
+	}
+	return ""
+}
+
+// TestForwarder_Collections confirms which activities are forwardable, and to which collections.
+func TestForwarder_Collections(t *testing.T) {
+
+	forwarder := NewForwarder(&recordingForwardSender{}, localOwner)
+
+	check := func(name string, value map[string]any, expected map[string][]string) {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expected, forwarder.Collections(streams.NewDocument(value)))
+		})
+	}
+
+	followers := map[string][]string{
+		"https://local.example/users/alice": {"https://local.example/users/alice/followers"},
+	}
+
+	// A reply to a local post, addressed to the local author's followers
+	check("reply", map[string]any{
+		"type":   "Create",
+		"actor":  "https://remote.example/users/bob",
+		"to":     []any{"https://local.example/users/alice", "https://local.example/users/alice/followers"},
+		"cc":     "https://local.example/users/alice/followers",
+		"object": map[string]any{"type": "Note", "inReplyTo": "https://local.example/posts/1"},
+	}, followers)
+
+	// A Like of a local post, addressed to the audience
+	check("audience", map[string]any{
+		"type":     "Like",
+		"audience": "https://local.example/users/alice/followers",
+		"object":   "https://local.example/posts/1",
+	}, followers)
+
+	// Not addressed to any local collection (local actors are not collections)
+	check("no local collection", map[string]any{
+		"type":   "Like",
+		"to":     "https://local.example/users/alice",
+		"object": "https://local.example/posts/1",
+	}, map[string][]string{})
+
+	// Addressed to a local collection, but only references remote objects
+	check("no local object", map[string]any{
+		"type":   "Create",
+		"to":     "https://local.example/users/alice/followers",
+		"object": map[string]any{"type": "Note", "inReplyTo": "https://remote.example/posts/1"},
+	}, map[string][]string{})
+
+	// Local objects that are nested too deeply are not found
+	check("too deep", map[string]any{
+		"type": "Announce",
+		"to":   "https://local.example/users/alice/followers",
+		"object": map[string]any{"object": map[string]any{"object": map[string]any{
+			"inReplyTo": "https://local.example/posts/1",
+		}}},
+	}, map[string][]string{})
+}
+
+// TestForwarder_Forward confirms the original body is handed to the ForwardSender once.
+func TestForwarder_Forward(t *testing.T) {
+
+	sender := &recordingForwardSender{}
+	forwarder := NewForwarder(sender, localOwner, ForwarderSeenStore(NewMemorySeenStore(), time.Hour))
+
+	activity := streams.NewDocument(map[string]any{
+		"id":     "https://remote.example/activities/1",
+		"type":   "Like",
+		"to":     "https://local.example/users/alice/followers",
+		"object": "https://local.example/posts/1",
+	})
+
+	body := []byte(`{"id": "https://remote.example/activities/1", "signature": "original"}`)
+
+	require.NoError(t, forwarder.Forward(activity, body))
+	require.NoError(t, forwarder.Forward(activity, body))
+
+	require.Len(t, sender.calls, 1, "activities must only be forwarded once")
+	assert.Equal(t, "https://local.example/users/alice", sender.calls[0].actorID)
+	assert.Equal(t, string(body), sender.calls[0].body)
+	assert.Equal(t, []string{"https://local.example/users/alice/followers"}, sender.calls[0].collections)
+
+	// Errors from the ForwardSender are returned
+	sender.err = derp.Internal("test", "queue is full")
+	failing := NewForwarder(sender, localOwner)
+	require.Error(t, failing.Forward(activity, body))
+}
+
+// TestReceiveRequest_Forwarding confirms ReceiveRequest forwards the exact bytes it received,
+// and still returns the activity when forwarding fails.
+func TestReceiveRequest_Forwarding(t *testing.T) {
+
+	body := `{"id":"https://remote.example/activities/1",  "type":"Like",
+		"to":"https://local.example/users/alice/followers", "object":"https://local.example/posts/1"}`
+
+	sender := &recordingForwardSender{}
+	valid := WithValidators(stubValidator{validator.ResultValid})
+
+	activity, err := ReceiveRequest(newActivityRequest(body), streams.NewDefaultClient(), valid, WithForwarder(NewForwarder(sender, localOwner)))
+	require.NoError(t, err)
+	assert.Equal(t, "https://remote.example/activities/1", activity.ID())
+
+	require.Len(t, sender.calls, 1)
+	assert.Equal(t, body, sender.calls[0].body)
+
+	// Forwarding failures do not reject the activity
+	sender.err = derp.Internal("test", "queue is full")
+	_, err = ReceiveRequest(newActivityRequest(body), streams.NewDefaultClient(), valid, WithForwarder(NewForwarder(sender, localOwner)))
+	require.NoError(t, err)
+
+	// Invalid activities are never forwarded
+	_, err = ReceiveRequest(newActivityRequest(body), streams.NewDefaultClient(), WithValidators(stubValidator{validator.ResultInvalid}), WithForwarder(NewForwarder(sender, localOwner)))
+	require.Error(t, err)
+	assert.Len(t, sender.calls, 2)
+}
+
+// TestReceiveRequest_Forwarding_Recipients confirms an activity delivered to several personal
+// inboxes is only forwarded once.
+func TestReceiveRequest_Forwarding_Recipients(t *testing.T) {
+
+	body := `{"id":"https://remote.example/activities/1", "type":"Like",
+		"to":"https://local.example/users/alice/followers", "object":"https://local.example/posts/1"}`
+
+	sender := &recordingForwardSender{}
+	forwarder := NewForwarder(sender, localOwner, ForwarderSeenStore(NewMemorySeenStore(), time.Hour))
+	valid := WithValidators(stubValidator{validator.ResultValid})
+
+	for _, recipientID := range []string{"https://local.example/users/alice", "https://local.example/users/bob"} {
+		request := newActivityRequest(body)
+		SetRecipientID(request, recipientID)
+
+		_, err := ReceiveRequest(request, streams.NewDefaultClient(), valid, WithForwarder(forwarder))
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, sender.calls, 1, "each activity must only be forwarded once, whichever inbox received it")
+}
+
+// TestForwarder_DefaultSeenStore confirms forwarders remember forwarded activities without
+// a configured SeenStore, and forward again after the ForwardSender fails.
+func TestForwarder_DefaultSeenStore(t *testing.T) {
+
+	sender := &recordingForwardSender{err: derp.Internal("test", "queue is full")}
+	forwarder := NewForwarder(sender, localOwner)
+
+	activity := streams.NewDocument(map[string]any{
+		"id":     "https://remote.example/activities/1",
+		"type":   "Like",
+		"to":     "https://local.example/users/alice/followers",
+		"object": "https://local.example/posts/1",
+	})
+
+	body := []byte(`{"id": "https://remote.example/activities/1"}`)
+
+	// Failures are forgotten, so the retry is forwarded
+	require.Error(t, forwarder.Forward(activity, body))
+
+	sender.err = nil
+	require.NoError(t, forwarder.Forward(activity, body))
+	require.NoError(t, forwarder.Forward(activity, body))
+
+	assert.Len(t, sender.calls, 2)
+}
@@ -0,0 +1,212 @@
+package router
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/rs/zerolog/log"
+)
+
+// HTTPContextFunc builds the context value that handlers receive for a single inbox
+// request, for instance by looking up the local actor that owns the inbox.
+type HTTPContextFunc[T any] func(request *http.Request) (T, error)
+
+// HTTPHandler returns an http.Handler for an inbox endpoint. It accepts only POST requests
+// with an ActivityPub content type, builds each request's context with the contextFunc,
+// then receives and handles the activity (the same way as ReceiveAndHandle) and writes a
+// status code that peers understand:
+//
+//   - 202 Accepted when the activity was handled (or was a duplicate)
+//   - 400 Bad Request for malformed activities and other client errors
+//   - 401 Unauthorized when the activity cannot be validated
+//   - 403 Forbidden (or another 4xx code) when a handler returns a RefusedError
+//   - 404 Not Found when the contextFunc cannot find the inbox
+//   - 405 Method Not Allowed and 415 Unsupported Media Type for the wrong kind of request
+//   - 413 Request Entity Too Large when the body exceeds the configured maximum
+//   - 503 Service Unavailable (with a Retry-After header) when a handler is rate limited,
+//     or when a copy of the activity is still being handled
+//   - 500 Internal Server Error for everything else (these errors are also reported)
+//
+// Only problems with the request itself are reported to the peer as client errors. Other
+// errors from handlers, such as a 404 or 451 from a document that a handler tried to load,
+// are server errors, because the peer did nothing wrong.
+func (router *Router[T]) HTTPHandler(contextFunc HTTPContextFunc[T], client streams.Client, options ...Option) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+
+		response := router.serveInbox(w, request, func() (T, error) {
+			return contextFunc(request)
+		}, client, options...)
+
+		writeInboxHeaders(w.Header(), response)
+
+		if response.statusCode == http.StatusAccepted {
+			w.WriteHeader(response.statusCode)
+			return
+		}
+
+		http.Error(w, response.message, response.statusCode)
+	})
+}
+
+// inboxResponse is the response that an inbox endpoint writes for a single request
+type inboxResponse struct {
+	statusCode int
+	message    string
+	retryAfter time.Duration // Included in a Retry-After header, if greater than zero
+}
+
+// serveInbox runs the steps shared by the inbox adapters, and returns the response to write.
+func (router *Router[T]) serveInbox(w http.ResponseWriter, request *http.Request, contextFunc func() (T, error), client streams.Client, options ...Option) inboxResponse {
+
+	const location = "hannibal.router.serveInbox"
+
+	// RULE: Inboxes only accept POST requests
+	if request.Method != http.MethodPost {
+		return inboxResponse{statusCode: http.StatusMethodNotAllowed, message: "Inbox only accepts POST requests"}
+	}
+
+	// RULE: Inboxes only accept ActivityPub documents
+	if !hannibal.IsActivityPubContentType(request.Header.Get("Content-Type")) {
+		return inboxResponse{statusCode: http.StatusUnsupportedMediaType, message: "Content-Type must be " + vocab.ContentTypeActivityPub}
+	}
+
+	// RULE: Refuse bodies that are too large before reading them
+	maxBodySize := NewReceiveConfig(options...).MaxBodySize
+
+	if request.ContentLength > maxBodySize {
+		return inboxResponse{statusCode: http.StatusRequestEntityTooLarge, message: "Request body is too large"}
+	}
+
+	request.Body = http.MaxBytesReader(w, request.Body, maxBodySize)
+
+	// Build the context for this request
+	context, err := contextFunc()
+
+	if err != nil {
+		return requestStatus(derp.Wrap(err, location, "Unable to build context for inbox request"))
+	}
+
+	// Receive and validate the activity
+	activity, err := ReceiveRequest(request, client, append([]Option{router.withRouteValidators()}, options...)...)
+
+	if err != nil {
+		return requestStatus(derp.Wrap(err, location, "Unable to receive ActivityPub request"))
+	}
+
+	// Route the activity to the appropriate handlers
+	if err := router.Handle(context, activity); err != nil {
+		return handlerStatus(derp.Wrap(err, location, "Unable to handle ActivityPub request"))
+	}
+
+	return inboxResponse{statusCode: http.StatusAccepted}
+}
+
+// requestStatus maps an error from receiving a request (building its context, reading,
+// parsing, or validating it) into an inbox response. Server errors are reported, because
+// the peer cannot do anything about them.
+func requestStatus(err error) inboxResponse {
+
+	var maxBytesError *http.MaxBytesError
+
+	if errors.As(err, &maxBytesError) {
+		return inboxResponse{statusCode: http.StatusRequestEntityTooLarge, message: "Request body is too large"}
+	}
+
+	switch statusCode := derp.ErrorCode(err); statusCode {
+
+	case http.StatusBadRequest,
+		http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusNotFound,
+		http.StatusRequestEntityTooLarge:
+
+		log.Trace().Err(err).Int("status", statusCode).Msg("Hannibal Router: inbox request refused")
+		return inboxResponse{statusCode: statusCode, message: http.StatusText(statusCode)}
+	}
+
+	if derp.IsClientError(err) {
+		log.Trace().Err(err).Msg("Hannibal Router: inbox request refused")
+		return inboxResponse{statusCode: http.StatusBadRequest, message: http.StatusText(http.StatusBadRequest)}
+	}
+
+	return serverErrorStatus(err)
+}
+
+// handlerStatus maps an error from the Router's handlers (or middleware) into an inbox
+// response. Only duplicates, refusals, and temporary conditions are passed to the peer.
+// Everything else is a server error.
+func handlerStatus(err error) inboxResponse {
+
+	// Duplicates were already accepted earlier
+	if IsDuplicateError(err) {
+		return inboxResponse{statusCode: http.StatusAccepted}
+	}
+
+	// Handlers can refuse an activity deliberately
+	var refusedError RefusedError
+
+	if errors.As(err, &refusedError) {
+		statusCode := refusedError.ErrorCode()
+		log.Trace().Err(err).Int("status", statusCode).Msg("Hannibal Router: inbox activity refused")
+		return inboxResponse{statusCode: statusCode, message: http.StatusText(statusCode)}
+	}
+
+	// Temporary conditions ask the peer to try again later
+	if tooManyRequests, retryAfter := derp.IsTooManyRequests(err); tooManyRequests {
+		log.Trace().Err(err).Msg("Hannibal Router: inbox activity is rate limited")
+		return unavailableStatus(retryAfter)
+	}
+
+	if IsInProgressError(err) {
+		return unavailableStatus(0)
+	}
+
+	return serverErrorStatus(err)
+}
+
+// unavailableStatus returns a "503 Service Unavailable" response that asks the peer to
+// retry after the provided duration (or a default, if it is empty)
+func unavailableStatus(retryAfter time.Duration) inboxResponse {
+
+	if retryAfter <= 0 {
+		retryAfter = inboxRetryAfter
+	}
+
+	return inboxResponse{
+		statusCode: http.StatusServiceUnavailable,
+		message:    http.StatusText(http.StatusServiceUnavailable),
+		retryAfter: retryAfter,
+	}
+}
+
+// serverErrorStatus reports an error, and returns a "500 Internal Server Error" response
+func serverErrorStatus(err error) inboxResponse {
+	derp.Report(err)
+	return inboxResponse{statusCode: http.StatusInternalServerError, message: http.StatusText(http.StatusInternalServerError)}
+}
+
+// writeInboxHeaders adds the headers that tell peers how to retry a refused request
+func writeInboxHeaders(header http.Header, response inboxResponse) {
+
+	switch response.statusCode {
+
+	case http.StatusMethodNotAllowed:
+		header.Set("Allow", http.MethodPost)
+
+	case http.StatusUnsupportedMediaType:
+		header.Set("Accept-Post", vocab.ContentTypeActivityPub)
+	}
+
+	if response.retryAfter > 0 {
+		seconds := int64(math.Ceil(response.retryAfter.Seconds()))
+		header.Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+}
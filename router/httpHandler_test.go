@@ -0,0 +1,124 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/clients"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/validator"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+)
+
+// newInboxRouter returns a Router whose Follow handler returns the provided error
+func newInboxRouter(handlerErr error) *Router[*capture] {
+	router := New[*capture]()
+	router.Add(vocab.ActivityTypeFollow, vocab.Any, func(context *capture, activity streams.Document) error {
+		context.hit = "follow"
+		return handlerErr
+	})
+	return &router
+}
+
+// serveHTTPInbox sends a request through an HTTPHandler, and returns the recorded response
+func serveHTTPInbox(router *Router[*capture], contextErr error, request *http.Request, options ...Option) *httptest.ResponseRecorder {
+
+	contextFunc := func(request *http.Request) (*capture, error) {
+		return &capture{}, contextErr
+	}
+
+	// Later options (such as a different validator) override the valid default
+	options = append([]Option{WithValidators(stubValidator{validator.ResultValid})}, options...)
+
+	recorder := httptest.NewRecorder()
+	router.HTTPHandler(contextFunc, streams.NewDefaultClient(), options...).ServeHTTP(recorder, request)
+	return recorder
+}
+
+// TestHTTPHandler_StatusCodes confirms the status codes and headers written for each kind of request.
+func TestHTTPHandler_StatusCodes(t *testing.T) {
+
+	check := func(name string, expected int, recorder *httptest.ResponseRecorder) {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expected, recorder.Code)
+		})
+	}
+
+	check("accepted", http.StatusAccepted, serveHTTPInbox(newInboxRouter(nil), nil, newActivityRequest(followActivityJSON)))
+	check("duplicate", http.StatusAccepted, serveHTTPInbox(newInboxRouter(DuplicateError{Key: "id:1"}), nil, newActivityRequest(followActivityJSON)))
+	check("malformed", http.StatusBadRequest, serveHTTPInbox(newInboxRouter(nil), nil, newActivityRequest(`{not json`)))
+	check("refused", http.StatusForbidden, serveHTTPInbox(newInboxRouter(RefusedError{Err: derp.Forbidden("test", "blocked")}), nil, newActivityRequest(followActivityJSON)))
+	check("refused with status", http.StatusGone, serveHTTPInbox(newInboxRouter(RefusedError{StatusCode: http.StatusGone}), nil, newActivityRequest(followActivityJSON)))
+	check("unknown inbox", http.StatusNotFound, serveHTTPInbox(newInboxRouter(nil), derp.NotFound("test", "no such actor"), newActivityRequest(followActivityJSON)))
+	check("server error", http.StatusInternalServerError, serveHTTPInbox(newInboxRouter(derp.Internal("test", "database is down")), nil, newActivityRequest(followActivityJSON)))
+	check("unauthorized", http.StatusUnauthorized, serveHTTPInbox(newInboxRouter(nil), nil, newActivityRequest(followActivityJSON), WithValidators(stubValidator{validator.ResultInvalid})))
+}
+
+// TestHTTPHandler_HandlerErrors confirms client errors from handlers (such as a document that a
+// handler could not load) are server errors, and temporary conditions ask the peer to retry.
+func TestHTTPHandler_HandlerErrors(t *testing.T) {
+
+	check := func(name string, expected int, handlerErr error) *httptest.ResponseRecorder {
+		recorder := serveHTTPInbox(newInboxRouter(handlerErr), nil, newActivityRequest(followActivityJSON))
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expected, recorder.Code)
+		})
+		return recorder
+	}
+
+	check("forbidden fetch", http.StatusInternalServerError, derp.Forbidden("test", "remote server refused the request"))
+	check("missing document", http.StatusInternalServerError, derp.NotFound("test", "remote document is gone"))
+	check("blocked domain", http.StatusInternalServerError, derp.Wrap(clients.DomainBlockedError{Host: "blocked.example"}, "test", "domain is blocked"))
+
+	recorder := check("rate limited", http.StatusServiceUnavailable, derp.Wrap(clients.RateLimitError{Host: "remote.example", RetryAfter: time.Minute}, "test", "remote server is busy"))
+	assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+
+	recorder = check("in progress", http.StatusServiceUnavailable, InProgressError{Key: "id:1"})
+	assert.Equal(t, "60", recorder.Header().Get("Retry-After"))
+}
+
+// TestHTTPHandler_RequestRules confirms requests with the wrong method, content type, or size are refused before they are read.
+func TestHTTPHandler_RequestRules(t *testing.T) {
+
+	router := newInboxRouter(nil)
+
+	// Wrong method
+	recorder := serveHTTPInbox(router, nil, httptest.NewRequest(http.MethodGet, "https://example.com/inbox", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	assert.Equal(t, http.MethodPost, recorder.Header().Get("Allow"))
+
+	// Wrong content type
+	request := newActivityRequest(followActivityJSON)
+	request.Header.Set("Content-Type", "text/html")
+	recorder = serveHTTPInbox(router, nil, request)
+	assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+	assert.Equal(t, vocab.ContentTypeActivityPub, recorder.Header().Get("Accept-Post"))
+
+	// JSON-LD with a profile is also ActivityPub
+	request = newActivityRequest(followActivityJSON)
+	request.Header.Set("Content-Type", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
+	assert.Equal(t, http.StatusAccepted, serveHTTPInbox(router, nil, request).Code)
+
+	// Declared size is too large
+	recorder = serveHTTPInbox(router, nil, newActivityRequest(followActivityJSON), WithMaxBodySize(16))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+
+	// Undeclared size is too large
+	request = newActivityRequest(followActivityJSON)
+	request.ContentLength = -1
+	request.Body = readCloser{strings.NewReader(followActivityJSON)}
+	recorder = serveHTTPInbox(router, nil, request, WithMaxBodySize(16))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
+
+// readCloser hides the length of a request body
+type readCloser struct {
+	*strings.Reader
+}
+
+func (readCloser) Close() error { return nil }
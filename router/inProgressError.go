@@ -0,0 +1,30 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+)
+
+// InProgressError is returned by the Dedup middleware when a copy of an activity arrives while
+// another copy is still being handled. The activity is not passed to any handlers. Its ErrorCode
+// is HTTP 503 (Service Unavailable) so that the peer retries later: if the first attempt fails,
+// the retry is handled normally, and if it succeeds, the retry is a DuplicateError.
+type InProgressError struct {
+	Key string // Key that identified the activity (see DuplicateError)
+}
+
+// Error implements the error interface
+func (err InProgressError) Error() string {
+	return "hannibal.router.Dedup: activity is already being handled: " + err.Key
+}
+
+// ErrorCode returns HTTP 503 (Service Unavailable) because the peer should try again later
+func (err InProgressError) ErrorCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// IsInProgressError returns TRUE if the provided error (or any error that it wraps) is an InProgressError
+func IsInProgressError(err error) bool {
+	var inProgressError InProgressError
+	return errors.As(err, &inProgressError)
+}
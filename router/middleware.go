@@ -0,0 +1,42 @@
+package router
+
+import (
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+)
+
+// Middleware wraps a RouteHandler with behavior that is shared by many routes, such as
+// logging, metrics, blocklists, or de-duplication. A Middleware can do work before and
+// after calling the `next` handler, or can short-circuit by returning without calling it.
+type Middleware[T any] func(next RouteHandler[T]) RouteHandler[T]
+
+// Recover is a Middleware that converts a panic in any later handler into an error,
+// so that one malformed activity cannot crash the server.
+func Recover[T any]() Middleware[T] {
+
+	const location = "hannibal.router.Recover"
+
+	return func(next RouteHandler[T]) RouteHandler[T] {
+		return func(context T, activity streams.Document) (err error) {
+
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					err = derp.Internal(location, "Route handler panicked", activity.ID(), recovered)
+				}
+			}()
+
+			return next(context, activity)
+		}
+	}
+}
+
+// applyMiddleware wraps a RouteHandler in a list of Middleware. The first Middleware
+// in the list is the outermost, so it runs first.
+func applyMiddleware[T any](handler RouteHandler[T], middleware []Middleware[T]) RouteHandler[T] {
+
+	for index := len(middleware) - 1; index >= 0; index-- {
+		handler = middleware[index](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,137 @@
+package router
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trace is a test context that records the order in which middleware and handlers run
+type trace struct {
+	steps []string
+}
+
+// tracer returns a Middleware that records its label before and after calling the next handler
+func tracer(label string) Middleware[*trace] {
+	return func(next RouteHandler[*trace]) RouteHandler[*trace] {
+		return func(context *trace, activity streams.Document) error {
+			context.steps = append(context.steps, label+":before")
+			err := next(context, activity)
+			context.steps = append(context.steps, label+":after")
+			return err
+		}
+	}
+}
+
+// traceHandler returns a RouteHandler that records its label
+func traceHandler(label string) RouteHandler[*trace] {
+	return func(context *trace, activity streams.Document) error {
+		context.steps = append(context.steps, label)
+		return nil
+	}
+}
+
+// TestRouter_Use confirms router middleware runs in order, outside of per-route middleware.
+func TestRouter_Use(t *testing.T) {
+
+	router := New[*trace]()
+	router.Use(tracer("first"), tracer("second"))
+	router.Add(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, traceHandler("handler"), tracer("route"))
+
+	context := &trace{}
+	require.NoError(t, router.Handle(context, activityDoc(vocab.ActivityTypeCreate, vocab.ObjectTypeNote)))
+
+	assert.Equal(t, []string{
+		"first:before",
+		"second:before",
+		"route:before",
+		"handler",
+		"route:after",
+		"second:after",
+		"first:after",
+	}, context.steps)
+}
+
+// TestRouter_Use_NoMatch confirms router middleware sees activities that do not match any route,
+// and that per-route middleware only runs for its own route.
+func TestRouter_Use_NoMatch(t *testing.T) {
+
+	router := New[*trace]()
+	router.Use(tracer("router"))
+	router.Add(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, traceHandler("handler"), tracer("route"))
+
+	context := &trace{}
+	require.NoError(t, router.Handle(context, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)))
+
+	assert.Equal(t, []string{"router:before", "router:after"}, context.steps)
+}
+
+// TestRouter_Use_ShortCircuit confirms middleware can stop an activity before it reaches the handler.
+func TestRouter_Use_ShortCircuit(t *testing.T) {
+
+	blocked := errors.New("blocked")
+
+	router := New[*trace]()
+	router.Use(func(next RouteHandler[*trace]) RouteHandler[*trace] {
+		return func(context *trace, activity streams.Document) error {
+			if activity.ActorID() == "https://blocked.example/users/troll" {
+				return blocked
+			}
+			return next(context, activity)
+		}
+	})
+	router.Add(vocab.Any, vocab.Any, traceHandler("handler"))
+
+	activity := streams.NewDocument(map[string]any{
+		vocab.PropertyType:   vocab.ActivityTypeCreate,
+		vocab.PropertyActor:  "https://blocked.example/users/troll",
+		vocab.PropertyObject: "https://blocked.example/notes/1",
+	})
+
+	context := &trace{}
+	assert.ErrorIs(t, router.Handle(context, activity), blocked)
+	assert.Empty(t, context.steps)
+
+	// Other actors pass through
+	require.NoError(t, router.Handle(context, activityDoc(vocab.ActivityTypeCreate, vocab.ObjectTypeNote)))
+	assert.Equal(t, []string{"handler"}, context.steps)
+}
+
+// TestRouter_Use_ImplicitCreate confirms middleware sees bare objects after they are wrapped in a Create.
+func TestRouter_Use_ImplicitCreate(t *testing.T) {
+
+	var activityType string
+
+	router := New[*trace]()
+	router.Use(func(next RouteHandler[*trace]) RouteHandler[*trace] {
+		return func(context *trace, activity streams.Document) error {
+			activityType = activity.Type()
+			return next(context, activity)
+		}
+	})
+
+	bareObject := streams.NewDocument(map[string]any{
+		vocab.PropertyID:   "https://example.com/note/1",
+		vocab.PropertyType: vocab.ObjectTypeNote,
+	})
+
+	require.NoError(t, router.Handle(&trace{}, bareObject))
+	assert.Equal(t, vocab.ActivityTypeCreate, activityType)
+}
+
+// TestRecover confirms a panicking handler returns an error instead of crashing.
+func TestRecover(t *testing.T) {
+
+	router := New[*trace]()
+	router.Use(Recover[*trace]())
+	router.Add(vocab.Any, vocab.Any, func(context *trace, activity streams.Document) error {
+		panic("malformed activity")
+	})
+
+	err := router.Handle(&trace{}, activityDoc(vocab.ActivityTypeCreate, vocab.ObjectTypeNote))
+	require.Error(t, err)
+}
@@ -27,6 +27,14 @@ func WithMaxBodySize(maxBytes int64) Option {
 	}
 }
 
+// WithForwarder forwards valid inbound activities that are addressed to local collections
+// (ActivityPub section 7.1.2) using the provided Forwarder.
+func WithForwarder(forwarder Forwarder) Option {
+	return func(config *ReceiveConfig) {
+		config.Forwarder = &forwarder
+	}
+}
+
 // WithPublicKeyFinder configures the HTTP signature validator to use the
 // provided public key finder when verifying inbound requests. This replaces
 // the default HTTPSig validator (which loads the key from the inbound document)
@@ -14,7 +14,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// ReceiveRequest reads an incoming HTTP request and returns a parsed and validated ActivityPub activity
+// ReceiveRequest reads an incoming HTTP request and returns a parsed and validated ActivityPub activity.
+// The activity carries the request's context (see streams.Document.RequestContext) so that remote
+// documents loaded while validating and handling it are cancelled if the peer disconnects.
 func ReceiveRequest(request *http.Request, client streams.Client, options ...Option) (activity streams.Document, err error) {
 
 	const location = "hannibal.router.ReceiveRequest"
@@ -30,7 +32,10 @@ func ReceiveRequest(request *http.Request, client streams.Client, options ...Opt
 	}
 
 	// Try to retrieve the object from the buffer
-	activity = streams.NilDocument(streams.WithClient(client))
+	activity = streams.NilDocument(
+		streams.WithClient(client),
+		streams.WithRequestContext(request.Context()),
+	)
 
 	// A body that will not parse is a malformed request from the peer, not a server fault, so it
 	// returns 400 Bad Request. json.Unmarshal errors are otherwise codeless and would surface as a
@@ -50,12 +55,28 @@ func ReceiveRequest(request *http.Request, client streams.Client, options ...Opt
 		fmt.Println("")
 	}
 
-	// Validate the activity using injected Validators
-	if isValid := validateRequest(request, &activity, config.Validators); !isValid {
+	// Validate the activity using injected Validators (or the chain for this activity's route)
+	validators := config.Validators
+
+	if config.RouteValidators != nil {
+		if routeValidators, ok := config.RouteValidators(activity); ok {
+			validators = routeValidators
+		}
+	}
+
+	if isValid := validateRequest(request, &activity, validators); !isValid {
 		log.Trace().Msg("Hannibal Router: Received activity is not valid")
 		return streams.NilDocument(), derp.Unauthorized(location, "Cannot validate received activity", activity.Value())
 	}
 
+	// Forward the original body to local collections (if configured). Forwarding is a
+	// courtesy to other servers, so failures are reported but do not reject the activity.
+	if config.Forwarder != nil {
+		if err := config.Forwarder.Forward(activity, body); err != nil {
+			derp.Report(derp.Wrap(err, location, "Unable to forward activity"))
+		}
+	}
+
 	// Return the parsed activity to the caller (vöïlä!)
 	return activity, nil
 }
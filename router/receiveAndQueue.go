@@ -0,0 +1,41 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/rosetta/mapof"
+	"github.com/benpate/turbine/queue"
+)
+
+// ReceiveAndQueue reads and validates an incoming HTTP request, then publishes the activity to
+// a queue so that it can be handled in the background by the Router's Consumer. Use this instead
+// of ReceiveAndHandle so that slow handlers do not make peers time out and retry. When it
+// returns without an error, the caller should respond "202 Accepted" right away.
+//
+// The recipientID (usually the ID of the local actor that owns the inbox, or empty for a shared
+// inbox) is passed back to the Consumer's ContextFunc to rebuild the handler's context.
+func (router *Router[T]) ReceiveAndQueue(q *queue.Queue, recipientID string, request *http.Request, client streams.Client, options ...Option) error {
+
+	const location = "hannibal.router.ReceiveAndQueue"
+
+	// Receive and validate the activity while we still have the original request
+	activity, err := ReceiveRequest(request, client, append([]Option{router.withRouteValidators()}, options...)...)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to receive ActivityPub request")
+	}
+
+	// Queue a task to handle the activity in the background
+	task := queue.NewTask(InboxHandleActivity, mapof.Any{
+		"recipient": recipientID,
+		"activity":  activity.Value(),
+	})
+
+	if err := q.Publish(task); err != nil {
+		return derp.Wrap(err, location, "Unable to enqueue inbound activity", activity.ID())
+	}
+
+	return nil
+}
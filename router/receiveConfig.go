@@ -1,6 +1,7 @@
 package router
 
 import (
+	"github.com/benpate/hannibal/streams"
 	"github.com/benpate/hannibal/validator"
 	"github.com/benpate/re"
 )
@@ -8,7 +9,12 @@ import (
 // ReceiveConfig is a configuration object for the `ReceiveRequest` function.
 type ReceiveConfig struct {
 	Validators  []Validator
-	MaxBodySize int64 // Maximum number of bytes to read from an inbound request body. Zero uses re.DefaultMaximum.
+	MaxBodySize int64      // Maximum number of bytes to read from an inbound request body. Zero uses re.DefaultMaximum.
+	Forwarder   *Forwarder // Optional Forwarder that re-delivers valid activities to local collections
+
+	// RouteValidators optionally returns a different validator chain for some activities,
+	// and FALSE to use the Validators chain. Routers set this from AddValidators.
+	RouteValidators func(activity streams.Document) ([]Validator, bool)
 }
 
 // NewReceiveConfig creates a new ReceiveConfig object with default settings,
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -102,6 +103,23 @@ func TestReceiveRequest_Valid(t *testing.T) {
 	assert.Equal(t, "https://example.com/activities/1", activity.ID())
 }
 
+// TestReceiveRequest_Context confirms the activity carries the request's context, so that
+// documents loaded while handling it are cancelled along with the request.
+func TestReceiveRequest_Context(t *testing.T) {
+
+	type contextKey struct{}
+	ctx := context.WithValue(context.Background(), contextKey{}, "inbox")
+
+	request := newActivityRequest(followActivityJSON).WithContext(ctx)
+
+	activity, err := ReceiveRequest(request, streams.NewDefaultClient(),
+		WithValidators(stubValidator{validator.ResultValid}))
+
+	require.NoError(t, err)
+	assert.Equal(t, "inbox", activity.RequestContext().Value(contextKey{}))
+	assert.Equal(t, "inbox", activity.Object().RequestContext().Value(contextKey{}))
+}
+
 // TestReceiveRequest_Rejected confirms a request that fails validation is
 // rejected with an error and a nil document.
 func TestReceiveRequest_Rejected(t *testing.T) {
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+)
+
+// RefusedError is returned by handlers (or middleware) that deliberately refuse an activity,
+// such as one from a blocked actor. Inbox endpoints pass its status code through to the peer.
+// Other errors that handlers return are server errors, even if they carry a 4xx code (for
+// instance, from a document that the handler tried to load).
+type RefusedError struct {
+	StatusCode int   // HTTP client error (400-499) for the peer. Defaults to 403 (Forbidden)
+	Err        error // Reason that the activity was refused, if any
+}
+
+// Error implements the error interface
+func (err RefusedError) Error() string {
+
+	message := "hannibal.router: activity refused"
+
+	if err.Err != nil {
+		message += ": " + err.Err.Error()
+	}
+
+	return message
+}
+
+// ErrorCode returns the StatusCode, or HTTP 403 (Forbidden) if it is not a client error
+func (err RefusedError) ErrorCode() int {
+
+	if (err.StatusCode >= 400) && (err.StatusCode < 500) {
+		return err.StatusCode
+	}
+
+	return http.StatusForbidden
+}
+
+// Unwrap returns the reason that the activity was refused, if any
+func (err RefusedError) Unwrap() error {
+	return err.Err
+}
+
+// IsRefusedError returns TRUE if the provided error (or any error that it wraps) is a RefusedError
+func IsRefusedError(err error) bool {
+	var refusedError RefusedError
+	return errors.As(err, &refusedError)
+}
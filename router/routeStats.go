@@ -0,0 +1,127 @@
+package router
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// RouteStats counts the activities that a Router has handled for a single activity/object
+// type pair, so that you can see which activities your peers send that you ignore.
+type RouteStats struct {
+	ActivityType string // Type of the activity, such as "Create"
+	ObjectType   string // Type of the activity's object, such as "Note"
+	Matched      int64  // Number of activities that matched a route
+	Unmatched    int64  // Number of activities that did not match any route
+}
+
+// RouteStatsOther is the activity and object type of the counter that collects every
+// activity/object type pair after the Router has counted routeStatsMaxKeys distinct pairs.
+const RouteStatsOther = "(other)"
+
+// routeStatsMaxKeys is the largest number of distinct activity/object type pairs that a
+// Router counts separately. Types come from remote peers, so this keeps them from growing
+// the counters without bound.
+const routeStatsMaxKeys = 1000
+
+// routeStats is a thread-safe set of counters, keyed by activity/object type
+type routeStats struct {
+	mutex    sync.Mutex
+	counters map[string]*RouteStats
+	maxKeys  int // Number of distinct pairs counted before the rest go to the RouteStatsOther counter
+}
+
+// newRouteStats returns a fully initialized routeStats
+func newRouteStats() *routeStats {
+	return &routeStats{
+		counters: make(map[string]*RouteStats),
+		maxKeys:  routeStatsMaxKeys,
+	}
+}
+
+// Stats returns the counters for every activity/object type pair that this Router has handled,
+// sorted by activity type, then object type. Activities with several object types are counted
+// under the first one. Only the first 1000 distinct pairs are counted separately. After that,
+// new pairs are counted together, with RouteStatsOther as their activity and object type.
+func (router *Router[T]) Stats() []RouteStats {
+	return router.stats.snapshot()
+}
+
+// ResetStats sets every counter back to zero.
+func (router *Router[T]) ResetStats() {
+	router.stats.reset()
+}
+
+// Unhandled returns the counters for activity/object type pairs that did not match a route,
+// with the most common first.
+func (router *Router[T]) Unhandled() []RouteStats {
+
+	result := slices.DeleteFunc(router.Stats(), func(stats RouteStats) bool {
+		return stats.Unmatched == 0
+	})
+
+	slices.SortStableFunc(result, func(a RouteStats, b RouteStats) int {
+		return cmp.Compare(b.Unmatched, a.Unmatched)
+	})
+
+	return result
+}
+
+// record counts a single activity
+func (stats *routeStats) record(activityType string, objectType string, matched bool) {
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	key := activityType + "/" + objectType
+	counter, ok := stats.counters[key]
+
+	if !ok {
+
+		// Once the limit is reached, new pairs share a single counter
+		if len(stats.counters) >= stats.maxKeys {
+			activityType, objectType = RouteStatsOther, RouteStatsOther
+			key = activityType + "/" + objectType
+			counter, ok = stats.counters[key]
+		}
+
+		if !ok {
+			counter = &RouteStats{ActivityType: activityType, ObjectType: objectType}
+			stats.counters[key] = counter
+		}
+	}
+
+	if matched {
+		counter.Matched++
+	} else {
+		counter.Unmatched++
+	}
+}
+
+// snapshot returns a sorted copy of every counter
+func (stats *routeStats) snapshot() []RouteStats {
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	result := make([]RouteStats, 0, len(stats.counters))
+
+	for _, counter := range stats.counters {
+		result = append(result, *counter)
+	}
+
+	slices.SortFunc(result, func(a RouteStats, b RouteStats) int {
+		return cmp.Or(cmp.Compare(a.ActivityType, b.ActivityType), cmp.Compare(a.ObjectType, b.ObjectType))
+	})
+
+	return result
+}
+
+// reset removes every counter
+func (stats *routeStats) reset() {
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.counters = make(map[string]*RouteStats)
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRouter_Stats confirms matched and unmatched activities are counted per activity/object pair.
+func TestRouter_Stats(t *testing.T) {
+
+	router := New[*capture]()
+	router.Add(vocab.ActivityTypeCreate, vocab.Any, handler("create"))
+
+	handle := func(activityType string, objectType string, count int) {
+		for range count {
+			require.NoError(t, router.Handle(&capture{}, activityDoc(activityType, objectType)))
+		}
+	}
+
+	handle(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, 3)
+	handle(vocab.ActivityTypeCreate, vocab.ObjectTypeArticle, 1)
+	handle(vocab.ActivityTypeLike, vocab.ObjectTypeNote, 2)
+	handle(vocab.ActivityTypeAnnounce, vocab.ObjectTypeNote, 5)
+
+	assert.Equal(t, []RouteStats{
+		{ActivityType: vocab.ActivityTypeAnnounce, ObjectType: vocab.ObjectTypeNote, Unmatched: 5},
+		{ActivityType: vocab.ActivityTypeCreate, ObjectType: vocab.ObjectTypeArticle, Matched: 1},
+		{ActivityType: vocab.ActivityTypeCreate, ObjectType: vocab.ObjectTypeNote, Matched: 3},
+		{ActivityType: vocab.ActivityTypeLike, ObjectType: vocab.ObjectTypeNote, Unmatched: 2},
+	}, router.Stats())
+
+	// Unhandled lists the most common unmatched pairs first
+	assert.Equal(t, []RouteStats{
+		{ActivityType: vocab.ActivityTypeAnnounce, ObjectType: vocab.ObjectTypeNote, Unmatched: 5},
+		{ActivityType: vocab.ActivityTypeLike, ObjectType: vocab.ObjectTypeNote, Unmatched: 2},
+	}, router.Unhandled())
+
+	router.ResetStats()
+	assert.Empty(t, router.Stats())
+}
+
+// TestRouter_Stats_Limit confirms new activity/object pairs share one counter once the limit is reached,
+// so that peers cannot grow the counters without bound.
+func TestRouter_Stats_Limit(t *testing.T) {
+
+	router := New[*capture]()
+	router.stats.maxKeys = 2
+
+	for _, objectType := range []string{vocab.ObjectTypeNote, vocab.ObjectTypeArticle, vocab.ObjectTypeImage, vocab.ObjectTypeVideo, vocab.ObjectTypeNote} {
+		_ = router.Handle(&capture{}, activityDoc(vocab.ActivityTypeLike, objectType))
+	}
+
+	assert.Equal(t, []RouteStats{
+		{ActivityType: RouteStatsOther, ObjectType: RouteStatsOther, Unmatched: 2},
+		{ActivityType: vocab.ActivityTypeLike, ObjectType: vocab.ObjectTypeArticle, Unmatched: 1},
+		{ActivityType: vocab.ActivityTypeLike, ObjectType: vocab.ObjectTypeNote, Unmatched: 2},
+	}, router.Stats())
+}
+
+// TestRouter_Stats_Concurrent confirms counters are safe to update from many goroutines.
+func TestRouter_Stats_Concurrent(t *testing.T) {
+
+	router := New[*capture]()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			_ = router.Handle(&capture{}, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote))
+		})
+	}
+	wg.Wait()
+
+	require.Len(t, router.Stats(), 1)
+	assert.Equal(t, int64(50), router.Stats()[0].Unmatched)
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+)
+
+// AddValidators replaces the validator chain for activities that match an activity type and
+// object type, so that stricter (or looser) checks apply only where they matter. Like Add,
+// you can use "*" as a wildcard, and the most specific match wins:
+// activity/object
+// */object
+// activity/*
+// */*
+//
+// Validator chains are chosen before the activity is validated, so linked objects are never
+// loaded to find their type. Only embedded objects match a specific object type.
+//
+// Activities that do not match any pattern use the chain from ReceiveConfig (see
+// WithValidators). Route validators are used by ReceiveAndHandle, ReceiveAndQueue,
+// SharedInbox.ReceiveAndHandle, and the HTTP adapters, but not by ReceiveRequest
+// when it is called directly.
+//
+// Like Add, this function is not thread-safe, and should be called before
+// starting the server.
+func (router *Router[T]) AddValidators(activityType string, objectType string, validators ...Validator) {
+	router.validators[activityType+"/"+objectType] = validators
+}
+
+// withRouteValidators returns an Option that finds the validator chain for each activity
+// from the validators added to this Router
+func (router *Router[T]) withRouteValidators() Option {
+	return func(config *ReceiveConfig) {
+		if len(router.validators) > 0 {
+			config.RouteValidators = router.matchValidators
+		}
+	}
+}
+
+// matchValidators returns the most specific validator chain for an activity, and
+// FALSE if no validators have been added for it. Bare objects are matched as implicit
+// "Create" activities, the same way that Handle routes them.
+//
+// The activity has not been validated yet, so nothing is loaded from the network: only
+// embedded objects have an object type. Linked objects match "activity/*" and "*/*" patterns.
+func (router *Router[T]) matchValidators(activity streams.Document) ([]Validator, bool) {
+
+	activity = implicitCreate(activity)
+	activityType := activity.Type()
+
+	// Loop through all object Type values (though there's usually just one) to find a matching chain.
+	// Linked objects are not loaded, so only embedded objects have a type.
+	if activityObject := activity.Object(); !activityObject.IsString() {
+
+		for _, objectType := range activityObject.Types() {
+
+			if validators, ok := router.validators[activityType+"/"+objectType]; ok {
+				return validators, true
+			}
+
+			if validators, ok := router.validators[vocab.Any+"/"+objectType]; ok {
+				return validators, true
+			}
+		}
+	}
+
+	if validators, ok := router.validators[activityType+"/"+vocab.Any]; ok {
+		return validators, true
+	}
+
+	if validators, ok := router.validators[vocab.Any+"/"+vocab.Any]; ok {
+		return validators, true
+	}
+
+	return nil, false
+}
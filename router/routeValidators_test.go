@@ -0,0 +1,126 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/validator"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRouter_MatchValidators confirms validator chains use the same wildcard precedence as routes.
+func TestRouter_MatchValidators(t *testing.T) {
+
+	exact := []Validator{stubValidator{validator.ResultValid}}
+	anyActivity := []Validator{stubValidator{validator.ResultInvalid}}
+	anyObject := []Validator{stubValidator{validator.ResultUnknown}}
+	catchAll := []Validator{}
+
+	router := New[*capture]()
+	router.AddValidators(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, exact...)
+	router.AddValidators(vocab.Any, vocab.ObjectTypeNote, anyActivity...)
+	router.AddValidators(vocab.ActivityTypeCreate, vocab.Any, anyObject...)
+
+	check := func(activityType string, objectType string, expected []Validator, expectedOK bool) {
+		validators, ok := router.matchValidators(activityDoc(activityType, objectType))
+		assert.Equal(t, expectedOK, ok, activityType+"/"+objectType)
+		assert.Equal(t, expected, validators, activityType+"/"+objectType)
+	}
+
+	check(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, exact, true)
+	check(vocab.ActivityTypeUpdate, vocab.ObjectTypeNote, anyActivity, true)
+	check(vocab.ActivityTypeCreate, vocab.ObjectTypeArticle, anyObject, true)
+	check(vocab.ActivityTypeUpdate, vocab.ObjectTypeArticle, nil, false)
+
+	router.AddValidators(vocab.Any, vocab.Any, catchAll...)
+	check(vocab.ActivityTypeUpdate, vocab.ObjectTypeArticle, catchAll, true)
+}
+
+// TestRouter_MatchValidators_Links confirms linked objects are never loaded to choose a validator
+// chain, because the activity has not been validated yet. They match "activity/*" patterns instead.
+func TestRouter_MatchValidators_Links(t *testing.T) {
+
+	client := newLinkClient()
+
+	router := New[*capture]()
+	router.AddValidators(vocab.ActivityTypeUndo, vocab.Any, stubValidator{validator.ResultValid})
+	router.AddValidators(vocab.ActivityTypeUndo, vocab.ActivityTypeFollow, stubValidator{validator.ResultInvalid})
+
+	undo := streams.NewDocument(map[string]any{
+		vocab.PropertyType:   vocab.ActivityTypeUndo,
+		vocab.PropertyObject: "https://example.com/follow/1",
+	}, streams.WithClient(client))
+
+	validators, ok := router.matchValidators(undo)
+	assert.True(t, ok)
+	assert.Equal(t, []Validator{stubValidator{validator.ResultValid}}, validators)
+	assert.Equal(t, 0, *client.loads)
+
+	// Embedded objects still match their own type
+	undo.SetProperty(vocab.PropertyObject, map[string]any{vocab.PropertyType: vocab.ActivityTypeFollow})
+
+	validators, ok = router.matchValidators(undo)
+	assert.True(t, ok)
+	assert.Equal(t, []Validator{stubValidator{validator.ResultInvalid}}, validators)
+	assert.Equal(t, 0, *client.loads)
+}
+
+// TestRouter_MatchValidators_ImplicitCreate confirms bare objects are matched as "Create"
+// activities, the same way that Handle routes them.
+func TestRouter_MatchValidators_ImplicitCreate(t *testing.T) {
+
+	createNote := []Validator{stubValidator{validator.ResultValid}}
+	anyNote := []Validator{stubValidator{validator.ResultInvalid}}
+
+	router := New[*capture]()
+	router.AddValidators(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, createNote...)
+	router.AddValidators(vocab.Any, vocab.ObjectTypeNote, anyNote...)
+
+	note := streams.NewDocument(map[string]any{
+		vocab.PropertyID:   "https://example.com/notes/1",
+		vocab.PropertyType: vocab.ObjectTypeNote,
+	})
+
+	validators, ok := router.matchValidators(note)
+	assert.True(t, ok)
+	assert.Equal(t, createNote, validators)
+
+	// Matching does not change the document itself
+	assert.Equal(t, vocab.ObjectTypeNote, note.Type())
+}
+
+// TestRouter_ReceiveAndHandle_RouteValidators confirms route validators replace the default chain.
+func TestRouter_ReceiveAndHandle_RouteValidators(t *testing.T) {
+
+	router := New[*capture]()
+	router.Add(vocab.Any, vocab.Any, handler("handled"))
+
+	// Follows are refused, even though the default chain accepts everything
+	router.AddValidators(vocab.ActivityTypeFollow, vocab.Any, stubValidator{validator.ResultInvalid})
+
+	// Creates are accepted, even though the default chain refuses everything
+	router.AddValidators(vocab.ActivityTypeCreate, vocab.Any, stubValidator{validator.ResultValid})
+
+	receive := func(body string, defaultResult validator.Result) (string, error) {
+		context := &capture{}
+		err := router.ReceiveAndHandle(context, newActivityRequest(body), streams.NewDefaultClient(), WithValidators(stubValidator{defaultResult}))
+		return context.hit, err
+	}
+
+	_, err := receive(followActivityJSON, validator.ResultValid)
+	require.Error(t, err)
+
+	hit, err := receive(`{"id":"https://example.com/1", "type":"Create", "object":{"type":"Note"}}`, validator.ResultInvalid)
+	require.NoError(t, err)
+	assert.Equal(t, "handled", hit)
+
+	hit, err = receive(`{"id":"https://example.com/2", "type":"Like", "object":{"type":"Note"}}`, validator.ResultValid)
+	require.NoError(t, err)
+	assert.Equal(t, "handled", hit)
+
+	// ReceiveRequest on its own only uses the default chain
+	_, err = ReceiveRequest(newActivityRequest(followActivityJSON), streams.NewDefaultClient(), WithValidators(stubValidator{validator.ResultValid}))
+	require.NoError(t, err)
+}
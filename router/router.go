@@ -3,7 +3,10 @@ package router
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/benpate/derp"
 	"github.com/benpate/hannibal/property"
@@ -14,13 +17,21 @@ import (
 
 // Router is a simple object that routes incoming ActivityPub activities to the appropriate handler
 type Router[T any] struct {
-	routes map[string]RouteHandler[T]
+	routes     map[string]RouteHandler[T]
+	nested     map[string]bool        // Prefixes (activity/object) of all three-segment routes
+	validators map[string][]Validator // Validator chains that replace the default chain for some activities
+	middleware []Middleware[T]
+	fallback   RouteHandler[T] // Optional handler for activities that do not match any route
+	stats      *routeStats     // Counts matched and unmatched activities
 }
 
 // New creates a new Router object
 func New[T any]() Router[T] {
 	result := Router[T]{
-		routes: make(map[string]RouteHandler[T]),
+		routes:     make(map[string]RouteHandler[T]),
+		nested:     make(map[string]bool),
+		validators: make(map[string][]Validator),
+		stats:      newRouteStats(),
 	}
 
 	return result
@@ -34,21 +45,69 @@ func New[T any]() Router[T] {
 // */object
 // */*
 //
+// Optional middleware wraps only this route, and runs after any middleware
+// registered with Use.
+//
 // For performance reasons, this function is not thread-safe.
 // So, you should add all routes before starting the server, for
 // instance, in your app's `init` functions.
-func (router *Router[T]) Add(activityType string, objectType string, routeHandler RouteHandler[T]) {
-	router.routes[activityType+"/"+objectType] = routeHandler
+func (router *Router[T]) Add(activityType string, objectType string, routeHandler RouteHandler[T], middleware ...Middleware[T]) {
+	router.AddPattern(activityType+"/"+objectType, routeHandler, middleware...)
+}
+
+// AddPattern puts a new route to the router, using a pattern of two or three types
+// separated by slashes. Two-segment patterns (such as "Create/Note") work the same as Add.
+// Three-segment patterns match nested activities by the type of the inner activity's
+// object, such as "Announce/Create/Note" or "Undo/Follow/*". Any segment can be a "*"
+// wildcard, and three-segment patterns are matched before two-segment patterns.
+//
+// Like Add, this function is not thread-safe, and should be called before
+// starting the server.
+func (router *Router[T]) AddPattern(pattern string, routeHandler RouteHandler[T], middleware ...Middleware[T]) {
+
+	router.routes[pattern] = applyMiddleware(routeHandler, middleware)
+
+	// Remember the prefixes of nested routes, so that inner objects are only loaded when they can match
+	if segments := strings.Split(pattern, "/"); len(segments) == 3 {
+		router.nested[segments[0]+"/"+segments[1]] = true
+	}
+}
+
+// Fallback sets a handler for activities that do not match any route, so that your
+// application can log, count, or store the activity types that it does not handle yet.
+// The fallback handler runs inside any middleware registered with Use.
+//
+// Like Add, this function is not thread-safe, and should be called before
+// starting the server.
+func (router *Router[T]) Fallback(routeHandler RouteHandler[T]) {
+	router.fallback = routeHandler
+}
+
+// Routes returns the patterns of every registered route, in alphabetical order.
+func (router *Router[T]) Routes() []string {
+	return slices.Sorted(maps.Keys(router.routes))
+}
+
+// Use adds middleware that wraps every activity passed to Handle, including activities
+// that do not match any route. Middleware runs in the order it was added, so the first
+// middleware is the outermost.
+//
+// Like Add, this function is not thread-safe, and should be called before
+// starting the server.
+func (router *Router[T]) Use(middleware ...Middleware[T]) {
+	router.middleware = append(router.middleware, middleware...)
 }
 
 // ReceiveAndHandle reads an incoming HTTP request, parses the ActivityPub activity,
 // and routes it to the appropriate handler.  This is the easiest way to use the Router.
+// If the Dedup middleware drops the activity, this returns a DuplicateError, which callers
+// should treat as a success (see IsDuplicateError).
 func (router *Router[T]) ReceiveAndHandle(context T, request *http.Request, client streams.Client, options ...Option) error {
 
 	const location = "hannibal.router.ReceiveAndHandle"
 
 	// Receive the activity from the request (with optional options)
-	activity, err := ReceiveRequest(request, client, options...)
+	activity, err := ReceiveRequest(request, client, append([]Option{router.withRouteValidators()}, options...)...)
 
 	if err != nil {
 		return derp.Wrap(err, location, "Unable to receive ActivityPub request")
@@ -63,31 +122,45 @@ func (router *Router[T]) ReceiveAndHandle(context T, request *http.Request, clie
 	return nil
 }
 
-// Handle takes an ActivityPub activity and routes it to the appropriate handler
+// Handle takes an ActivityPub activity and routes it (through any middleware) to the appropriate handler
 func (router *Router[T]) Handle(context T, activity streams.Document) error {
 
-	activityType := activity.Type()
-
 	// If this is a Document (not an Activity) then wrap it in
 	// an implicit "Create" activity before routing.
-	if activity.IsObject() {
-
-		newValue := property.Map{
-			vocab.AtContext:      activity.AtContext(),
-			vocab.PropertyID:     activity.ID(),
-			vocab.PropertyActor:  activity.Actor().ID(),
-			vocab.PropertyType:   vocab.ActivityTypeCreate,
-			vocab.PropertyObject: activity.Value(),
-		}
+	activity = implicitCreate(activity)
 
-		activity.SetValue(newValue)
-		activityType = vocab.ActivityTypeCreate
+	return applyMiddleware(router.dispatch, router.middleware)(context, activity)
+}
+
+// implicitCreate wraps a bare object (such as a Note) in an implicit "Create" activity, so
+// that it is routed like one. Activities are returned unchanged. Nothing is loaded from the
+// network, so this is safe to call before the activity has been validated.
+func implicitCreate(activity streams.Document) streams.Document {
+
+	if !activity.IsObject() {
+		return activity
 	}
 
+	activity.SetValue(property.Map{
+		vocab.AtContext:      activity.AtContext(),
+		vocab.PropertyID:     activity.ID(),
+		vocab.PropertyActor:  activity.ActorID(),
+		vocab.PropertyType:   vocab.ActivityTypeCreate,
+		vocab.PropertyObject: activity.Value(),
+	})
+
+	return activity
+}
+
+// dispatch finds the most specific route for an activity and calls its handler
+func (router *Router[T]) dispatch(context T, activity streams.Document) error {
+
+	activityType := activity.Type()
+
 	// Resolve the object AFTER any implicit-Create wrapping, so that object-type
 	// routing (e.g. Create/Note) sees the real wrapped object rather than the
 	// pre-wrap value.
-	activityObject := activity.Object().LoadLink()
+	activityObject, innerObject := router.unwrap(activity)
 
 	// Log all incoming activity... except delete messages because Mastodon is way too chatty
 	if canDebug() && (activityType != vocab.ActivityTypeDelete) {
@@ -99,30 +172,152 @@ func (router *Router[T]) Handle(context T, activity streams.Document) error {
 		}
 	}
 
+	routeHandler, pattern, nested, ok := router.match(activityType, activityObject, innerObject)
+	router.stats.record(activityType, activityObject.Type(), ok)
+
+	if ok {
+		log.Trace().Str("type", pattern).Msg("Hannibal Router: route matched.")
+
+		// Three-segment routes receive the nested documents they were matched on
+		if nested {
+			activity = embedNested(activity, activityObject, innerObject)
+		}
+
+		return routeHandler(context, activity)
+	}
+
+	log.Trace().Str("activity", activity.Type()).Str("object", activityObject.Type()).Msg("No match found for activity")
+
+	// Unmatched activities are passed to the fallback handler, if there is one
+	if router.fallback != nil {
+		return router.fallback(context, activity)
+	}
+
+	return nil
+}
+
+// match finds the most specific route for an activity, and returns its handler, its pattern,
+// and TRUE if the pattern is a three-segment route
+func (router *Router[T]) match(activityType string, activityObject streams.Document, innerObject streams.Document) (RouteHandler[T], string, bool, bool) {
+
+	// Nested activities (such as Announce/Create/Note) try three-segment routes first
+	if innerObject.NotNil() {
+		if routeHandler, pattern, ok := router.matchNested(activityType, activityObject.Types(), innerObject.Types()); ok {
+			return routeHandler, pattern, true, true
+		}
+	}
+
 	// Loop through all object Type values (though there's usually just one) to find a matching route
 	for _, objectType := range activityObject.Types() {
 
 		if routeHandler, ok := router.routes[activityType+"/"+objectType]; ok {
-			log.Trace().Str("type", activityType+"/"+objectType).Msg("Hannibal Router: route matched.")
-			return routeHandler(context, activity)
+			return routeHandler, activityType + "/" + objectType, false, true
 		}
 
 		if routeHandler, ok := router.routes[vocab.Any+"/"+objectType]; ok {
-			log.Trace().Str("type", "*/"+objectType).Msg("Hannibal Router: route matched.")
-			return routeHandler(context, activity)
+			return routeHandler, vocab.Any + "/" + objectType, false, true
 		}
 	}
 
 	if routeHandler, ok := router.routes[activityType+"/"+vocab.Any]; ok {
-		log.Trace().Str("type", activityType+"/*").Msg("Hannibal Router: route matched.")
-		return routeHandler(context, activity)
+		return routeHandler, activityType + "/" + vocab.Any, false, true
 	}
 
 	if routeHandler, ok := router.routes[vocab.Any+"/"+vocab.Any]; ok {
-		log.Trace().Str("type", "*/*").Msg("Hannibal Router: route matched.")
-		return routeHandler(context, activity)
+		return routeHandler, vocab.Any + "/" + vocab.Any, false, true
 	}
 
-	log.Trace().Str("activity", activity.Type()).Str("object", activityObject.Type()).Msg("No match found for activity")
-	return nil
+	return nil, "", false, false
+}
+
+// unwrap loads the activity's object and, if a three-segment route could match, the
+// object of a nested activity (such as the Note inside Announce/Create).
+func (router *Router[T]) unwrap(activity streams.Document) (streams.Document, streams.Document) {
+
+	activityObject := activity.Object().LoadLink()
+
+	if !activityObject.IsActivity() || !router.hasNested(activity.Type(), activityObject.Types()) {
+		return activityObject, streams.NilDocument()
+	}
+
+	return activityObject, activityObject.Object().LoadLink()
+}
+
+// hasNested returns TRUE if any three-segment route begins with this activity type and object type
+func (router *Router[T]) hasNested(activityType string, objectTypes []string) bool {
+
+	if len(router.nested) == 0 {
+		return false
+	}
+
+	for _, prefixType := range []string{activityType, vocab.Any} {
+
+		if router.nested[prefixType+"/"+vocab.Any] {
+			return true
+		}
+
+		for _, objectType := range objectTypes {
+			if router.nested[prefixType+"/"+objectType] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchNested finds the most specific three-segment route for a nested activity. Each
+// combination of wildcards is tried in turn, from least to most general, with the
+// activity type becoming a wildcard before the object types do.
+func (router *Router[T]) matchNested(activityType string, objectTypes []string, innerTypes []string) (RouteHandler[T], string, bool) {
+
+	for mask := range 8 {
+
+		for _, first := range wildcardTypes([]string{activityType}, mask&1 != 0) {
+			for _, second := range wildcardTypes(objectTypes, mask&2 != 0) {
+				for _, third := range wildcardTypes(innerTypes, mask&4 != 0) {
+
+					pattern := first + "/" + second + "/" + third
+
+					if routeHandler, ok := router.routes[pattern]; ok {
+						return routeHandler, pattern, true
+					}
+				}
+			}
+		}
+	}
+
+	return nil, "", false
+}
+
+// wildcardTypes returns a single wildcard if requested, or the original types otherwise
+func wildcardTypes(types []string, wildcard bool) []string {
+
+	if wildcard {
+		return []string{vocab.Any}
+	}
+
+	return types
+}
+
+// embedNested returns a copy of the activity with its object, and the object of
+// that nested activity, replaced by the documents that were loaded to match a
+// three-segment route. If neither was a link, the activity is returned as-is.
+func embedNested(activity streams.Document, activityObject streams.Document, innerObject streams.Document) streams.Document {
+
+	objectIsLink := activity.Object().IsString()
+	innerIsLink := activityObject.Object().IsString()
+
+	if !objectIsLink && !innerIsLink {
+		return activity
+	}
+
+	if innerIsLink && innerObject.IsMap() {
+		activityObject = activityObject.Clone()
+		activityObject.SetProperty(vocab.PropertyObject, innerObject.Value())
+	}
+
+	result := activity.Clone()
+	result.SetProperty(vocab.PropertyObject, activityObject.Value())
+	return result
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/benpate/derp"
 	"github.com/benpate/hannibal/streams"
 	"github.com/benpate/hannibal/vocab"
 	"github.com/stretchr/testify/assert"
@@ -161,3 +162,202 @@ func TestRouter_Handle_ImplicitCreate(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "implicit-create", context.hit, "a bare object must be routed as an implicit Create")
 }
+
+// linkClient is an in-memory streams.Client that resolves links from a fixed map,
+// and counts how many documents it loads.
+type linkClient struct {
+	documents map[string]map[string]any
+	loads     *int
+}
+
+func (client linkClient) SetRootClient(streams.Client) {}
+
+func (client linkClient) Load(uri string, options ...any) (streams.Document, error) {
+	*client.loads++
+	if value, ok := client.documents[uri]; ok {
+		return streams.NewDocument(value, streams.WithClient(client)), nil
+	}
+	return streams.NilDocument(), derp.NotFound("router.linkClient.Load", "Unknown URI", uri)
+}
+
+func (client linkClient) Save(streams.Document) error    { return nil }
+func (client linkClient) Delete(documentID string) error { return nil }
+
+// newLinkClient returns a linkClient that knows about a Follow, a Create, and a Note
+func newLinkClient() linkClient {
+	return linkClient{
+		loads: new(int),
+		documents: map[string]map[string]any{
+			"https://example.com/follow/1": {
+				vocab.PropertyID:     "https://example.com/follow/1",
+				vocab.PropertyType:   vocab.ActivityTypeFollow,
+				vocab.PropertyObject: "https://example.com/users/bob",
+			},
+			"https://example.com/create/1": {
+				vocab.PropertyID:     "https://example.com/create/1",
+				vocab.PropertyType:   vocab.ActivityTypeCreate,
+				vocab.PropertyObject: "https://example.com/note/1",
+			},
+			"https://example.com/note/1": {
+				vocab.PropertyID:   "https://example.com/note/1",
+				vocab.PropertyType: vocab.ObjectTypeNote,
+			},
+		},
+	}
+}
+
+// TestRouter_Handle_Nested confirms three-segment patterns match nested activities by the
+// type of the inner object, and that linked objects are embedded before the handler runs.
+func TestRouter_Handle_Nested(t *testing.T) {
+
+	client := newLinkClient()
+
+	router := New[*capture]()
+	router.Add(vocab.ActivityTypeAnnounce, vocab.Any, handler("announce-any"))
+	router.AddPattern("Announce/Create/Note", func(context *capture, activity streams.Document) error {
+		context.hit = "announce-create-note"
+
+		// Both levels were loaded and embedded, so the handler does not need to load them again
+		assert.True(t, activity.Object().IsMap())
+		assert.True(t, activity.Object().Object().IsMap())
+		assert.Equal(t, "https://example.com/note/1", activity.Object().Object().ID())
+		return nil
+	})
+
+	announce := streams.NewDocument(map[string]any{
+		vocab.PropertyType:   vocab.ActivityTypeAnnounce,
+		vocab.PropertyObject: "https://example.com/create/1",
+	}, streams.WithClient(client))
+
+	context := &capture{}
+	require.NoError(t, router.Handle(context, announce))
+	assert.Equal(t, "announce-create-note", context.hit)
+	assert.Equal(t, 2, *client.loads)
+
+	// The original activity is not modified
+	assert.True(t, announce.Object().IsString())
+
+	// Other nested activities fall back to two-segment routes
+	announcePerson := streams.NewDocument(map[string]any{
+		vocab.PropertyType:   vocab.ActivityTypeAnnounce,
+		vocab.PropertyObject: map[string]any{vocab.PropertyType: vocab.ActorTypePerson},
+	}, streams.WithClient(client))
+
+	context = &capture{}
+	require.NoError(t, router.Handle(context, announcePerson))
+	assert.Equal(t, "announce-any", context.hit)
+}
+
+// TestRouter_Handle_NestedFallback confirms that nested activities matched by a two-segment
+// route receive the original activity, even when its inner object was loaded for matching.
+func TestRouter_Handle_NestedFallback(t *testing.T) {
+
+	client := newLinkClient()
+
+	router := New[*capture]()
+	router.AddPattern("Announce/Create/Article", handler("announce-create-article"))
+	router.Add(vocab.ActivityTypeAnnounce, vocab.ActivityTypeCreate, func(context *capture, activity streams.Document) error {
+		context.hit = "announce-create"
+		assert.True(t, activity.Object().IsString(), "two-segment routes must receive the original activity")
+		return nil
+	})
+
+	announce := streams.NewDocument(map[string]any{
+		vocab.PropertyType:   vocab.ActivityTypeAnnounce,
+		vocab.PropertyObject: "https://example.com/create/1",
+	}, streams.WithClient(client))
+
+	context := &capture{}
+	require.NoError(t, router.Handle(context, announce))
+	assert.Equal(t, "announce-create", context.hit)
+}
+
+// TestRouter_Handle_NestedWildcards confirms wildcards in three-segment patterns, from most to least specific.
+func TestRouter_Handle_NestedWildcards(t *testing.T) {
+
+	undo := func() streams.Document {
+		return streams.NewDocument(map[string]any{
+			vocab.PropertyType: vocab.ActivityTypeUndo,
+			vocab.PropertyObject: map[string]any{
+				vocab.PropertyType:   vocab.ActivityTypeLike,
+				vocab.PropertyObject: map[string]any{vocab.PropertyType: vocab.ObjectTypeNote},
+			},
+		})
+	}
+
+	check := func(expected string, patterns ...string) {
+		router := New[*capture]()
+		for _, pattern := range patterns {
+			router.AddPattern(pattern, handler(pattern))
+		}
+
+		context := &capture{}
+		require.NoError(t, router.Handle(context, undo()))
+		assert.Equal(t, expected, context.hit)
+	}
+
+	check("Undo/Like/Note", "Undo/Like/Note", "*/Like/Note", "Undo/Like/*", "Undo/Like")
+	check("*/Like/Note", "*/Like/Note", "Undo/*/Note", "Undo/Like/*", "Undo/Like")
+	check("Undo/*/Note", "Undo/*/Note", "Undo/Like/*", "Undo/Like")
+	check("Undo/Like/*", "Undo/Like/*", "*/*/*", "Undo/Like")
+	check("*/*/*", "*/*/*", "Undo/Like")
+	check("Undo/Like", "Undo/Like", "Undo/Follow/*", "Create/Like/*")
+}
+
+// TestRouter_Handle_UndoLink confirms an Undo of a linked Follow is matched by the Follow's type,
+// that two-segment routes receive the original activity, and that the inner object is not
+// loaded when no three-segment routes could use it.
+func TestRouter_Handle_UndoLink(t *testing.T) {
+
+	client := newLinkClient()
+
+	router := New[*capture]()
+	router.Add(vocab.ActivityTypeUndo, vocab.ActivityTypeFollow, func(context *capture, activity streams.Document) error {
+		context.hit = vocab.ActivityTypeFollow
+		assert.True(t, activity.Object().IsString(), "two-segment routes must receive the original activity")
+		return nil
+	})
+
+	undo := streams.NewDocument(map[string]any{
+		vocab.PropertyType:   vocab.ActivityTypeUndo,
+		vocab.PropertyObject: "https://example.com/follow/1",
+	}, streams.WithClient(client))
+
+	context := &capture{}
+	require.NoError(t, router.Handle(context, undo))
+	assert.Equal(t, vocab.ActivityTypeFollow, context.hit)
+	assert.Equal(t, 1, *client.loads, "the followed actor must not be loaded")
+}
+
+// TestRouter_Routes confirms every registered pattern is listed, in order.
+func TestRouter_Routes(t *testing.T) {
+
+	router := New[*capture]()
+	assert.Empty(t, router.Routes())
+
+	router.Add(vocab.ActivityTypeFollow, vocab.Any, handler("follow"))
+	router.Add(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, handler("create-note"))
+	router.AddPattern("Announce/Create/Note", handler("announce-create-note"))
+
+	assert.Equal(t, []string{"Announce/Create/Note", "Create/Note", "Follow/*"}, router.Routes())
+}
+
+// TestRouter_Fallback confirms unmatched activities are passed to the fallback handler, inside any middleware.
+func TestRouter_Fallback(t *testing.T) {
+
+	router := New[*trace]()
+	router.Use(tracer("outer"))
+	router.Add(vocab.ActivityTypeCreate, vocab.ObjectTypeNote, traceHandler("create-note"))
+	router.Fallback(func(context *trace, activity streams.Document) error {
+		context.steps = append(context.steps, "fallback:"+activity.Type())
+		return nil
+	})
+
+	context := &trace{}
+	require.NoError(t, router.Handle(context, activityDoc(vocab.ActivityTypeLike, vocab.ObjectTypeNote)))
+	assert.Equal(t, []string{"outer:before", "fallback:Like", "outer:after"}, context.steps)
+
+	context = &trace{}
+	require.NoError(t, router.Handle(context, activityDoc(vocab.ActivityTypeCreate, vocab.ObjectTypeNote)))
+	assert.Equal(t, []string{"outer:before", "create-note", "outer:after"}, context.steps)
+}
@@ -0,0 +1,142 @@
+package router
+
+import (
+	"sync"
+	"time"
+)
+
+// SeenStatus describes what a SeenStore knows about a key
+type SeenStatus int
+
+// SeenStatusNew means the key has not been recorded (or has expired), so its activity should be handled
+const SeenStatusNew SeenStatus = 0
+
+// SeenStatusInProgress means another attempt to handle the key's activity is still running
+const SeenStatusInProgress SeenStatus = 1
+
+// SeenStatusSeen means the key's activity has already been handled successfully
+const SeenStatusSeen SeenStatus = 2
+
+// SeenStore records the activities that have already been received, so that the
+// Dedup middleware can recognize duplicates. Activities that are still being handled
+// are recorded separately from those that have been handled successfully. Implementations
+// must be safe for concurrent use, and MarkInProgress and MarkSeen must check-and-set atomically.
+type SeenStore interface {
+
+	// MarkInProgress records that the key's activity is being handled, for no longer than the TTL,
+	// and returns SeenStatusNew. If the key has already been recorded (and has not yet expired), then
+	// nothing changes, and it returns SeenStatusInProgress or SeenStatusSeen instead.
+	MarkInProgress(key string, ttl time.Duration) (SeenStatus, error)
+
+	// MarkSeen records that the key's activity has been handled, for the duration of the TTL, and
+	// returns TRUE if it had already been recorded as handled (and has not yet expired). Keys that
+	// are only in progress are not "already seen".
+	MarkSeen(key string, ttl time.Duration) (alreadySeen bool, err error)
+
+	// Forget removes a key, so that the next activity with the same key is not a duplicate.
+	Forget(key string) error
+}
+
+// MemorySeenStore is an in-memory SeenStore. Its contents are lost when the server restarts,
+// and are not shared between servers, so use a database-backed SeenStore in a cluster.
+type MemorySeenStore struct {
+	mutex     sync.Mutex
+	entries   map[string]seenEntry
+	pruneSize int // Size of the map that triggers the next pruning of expired keys
+	now       func() time.Time
+}
+
+// seenEntry is a single key in a MemorySeenStore
+type seenEntry struct {
+	status  SeenStatus
+	expires time.Time
+}
+
+// NewMemorySeenStore returns a fully initialized MemorySeenStore
+func NewMemorySeenStore() *MemorySeenStore {
+	return &MemorySeenStore{
+		entries:   make(map[string]seenEntry),
+		pruneSize: 1024,
+		now:       time.Now,
+	}
+}
+
+// MarkInProgress is a part of the SeenStore interface.
+// It records the key as in progress, unless the key has already been recorded.
+func (store *MemorySeenStore) MarkInProgress(key string, ttl time.Duration) (SeenStatus, error) {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+
+	if entry, ok := store.entries[key]; ok && now.Before(entry.expires) {
+		return entry.status, nil
+	}
+
+	store.set(key, SeenStatusInProgress, now.Add(ttl), now)
+	return SeenStatusNew, nil
+}
+
+// MarkSeen is a part of the SeenStore interface.
+// It records the key as handled and returns TRUE if the key had already been handled.
+func (store *MemorySeenStore) MarkSeen(key string, ttl time.Duration) (bool, error) {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+
+	if entry, ok := store.entries[key]; ok && (entry.status == SeenStatusSeen) && now.Before(entry.expires) {
+		return true, nil
+	}
+
+	store.set(key, SeenStatusSeen, now.Add(ttl), now)
+	return false, nil
+}
+
+// Forget is a part of the SeenStore interface.
+// It removes a key from the store.
+func (store *MemorySeenStore) Forget(key string) error {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.entries, key)
+	return nil
+}
+
+// Len returns the number of keys in the store, including any that have expired but not yet been removed.
+func (store *MemorySeenStore) Len() int {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return len(store.entries)
+}
+
+// set records a key. The caller must hold the mutex.
+func (store *MemorySeenStore) set(key string, status SeenStatus, expires time.Time, now time.Time) {
+
+	store.entries[key] = seenEntry{status: status, expires: expires}
+
+	// Remove expired keys whenever the map doubles in size
+	if len(store.entries) >= store.pruneSize {
+		store.prune(now)
+	}
+}
+
+// prune removes expired keys. The caller must hold the mutex.
+func (store *MemorySeenStore) prune(now time.Time) {
+
+	for key, entry := range store.entries {
+		if !now.Before(entry.expires) {
+			delete(store.entries, key)
+		}
+	}
+
+	store.pruneSize = max(1024, len(store.entries)*2)
+}
+
+// Verify that MemorySeenStore satisfies the SeenStore interface.
+var _ SeenStore = &MemorySeenStore{}
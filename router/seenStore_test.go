@@ -0,0 +1,94 @@
+package router
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemorySeenStore confirms keys are remembered until their TTL expires.
+func TestMemorySeenStore(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemorySeenStore()
+	store.now = func() time.Time { return now }
+
+	seen, err := store.MarkSeen("id:1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	seen, err = store.MarkSeen("id:1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	// Expired keys are new again
+	now = now.Add(time.Hour)
+	seen, err = store.MarkSeen("id:1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	// Forgotten keys are new again
+	require.NoError(t, store.Forget("id:1"))
+	seen, err = store.MarkSeen("id:1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, seen)
+}
+
+// TestMemorySeenStore_InProgress confirms keys that are in progress are kept separate from
+// keys that have been handled.
+func TestMemorySeenStore_InProgress(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemorySeenStore()
+	store.now = func() time.Time { return now }
+
+	status, err := store.MarkInProgress("id:1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, SeenStatusNew, status)
+
+	status, err = store.MarkInProgress("id:1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, SeenStatusInProgress, status)
+
+	// Completing an activity that is in progress is not a duplicate
+	seen, err := store.MarkSeen("id:1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	status, err = store.MarkInProgress("id:1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, SeenStatusSeen, status)
+
+	// Keys that stay in progress too long are new again
+	status, err = store.MarkInProgress("id:2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, SeenStatusNew, status)
+
+	now = now.Add(time.Minute)
+	status, err = store.MarkInProgress("id:2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, SeenStatusNew, status)
+}
+
+// TestMemorySeenStore_Prune confirms expired keys are removed as the store grows.
+func TestMemorySeenStore_Prune(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemorySeenStore()
+	store.now = func() time.Time { return now }
+
+	for index := range 1000 {
+		_, _ = store.MarkSeen("old:"+strconv.Itoa(index), time.Minute)
+	}
+
+	now = now.Add(time.Hour)
+
+	for index := range 100 {
+		_, _ = store.MarkSeen("new:"+strconv.Itoa(index), time.Minute)
+	}
+
+	assert.Equal(t, 100, store.Len())
+}
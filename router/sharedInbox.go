@@ -0,0 +1,212 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/rosetta/ranges"
+	"github.com/rs/zerolog/log"
+)
+
+// FollowersFunc returns the IDs of the local actors that follow a (remote) actor.
+type FollowersFunc func(actorID string) ([]string, error)
+
+// SharedInbox fans out the activities that arrive at a shared inbox to every local actor
+// that they concern, calling the Router's handlers once for each one. Local recipients are:
+//
+//   - local actors in the activity's to, cc, bto, bcc, and audience properties
+//   - local actors that are mentioned by the activity, or by its embedded object
+//   - local followers of the sender, if the activity is addressed to the sender's followers
+//
+// Each recipient is handled only once, even if it is addressed in several places.
+type SharedInbox[T any] struct {
+	router      *Router[T]
+	owner       OwnerFunc
+	followers   FollowersFunc
+	contextFunc ContextFunc[T]
+}
+
+// NewSharedInbox returns a fully initialized SharedInbox. The OwnerFunc identifies local actors
+// (an actor owns itself), the FollowersFunc finds local followers of the sender (nil to skip
+// followers), and the ContextFunc builds the handler context for each local recipient.
+func NewSharedInbox[T any](router *Router[T], owner OwnerFunc, followers FollowersFunc, contextFunc ContextFunc[T]) SharedInbox[T] {
+	return SharedInbox[T]{
+		router:      router,
+		owner:       owner,
+		followers:   followers,
+		contextFunc: contextFunc,
+	}
+}
+
+// ReceiveAndHandle reads an incoming HTTP request, parses the ActivityPub activity,
+// and routes it to the appropriate handler once for each local recipient.
+func (inbox SharedInbox[T]) ReceiveAndHandle(request *http.Request, client streams.Client, options ...Option) error {
+
+	const location = "hannibal.router.SharedInbox.ReceiveAndHandle"
+
+	// Receive the activity from the request (with optional options)
+	activity, err := ReceiveRequest(request, client, append([]Option{inbox.router.withRouteValidators()}, options...)...)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to receive ActivityPub request")
+	}
+
+	// Route the activity to each local recipient
+	if err := inbox.Handle(activity); err != nil {
+		return derp.Wrap(err, location, "Unable to handle ActivityPub request")
+	}
+
+	// Success.
+	return nil
+}
+
+// Handle routes an activity to the appropriate handler once for each local recipient. Every
+// recipient is handled, even if an earlier one fails, and the first error is returned.
+// Duplicates reported by the Dedup middleware are skipped. Use RecipientID to find out which
+// recipient is being handled.
+func (inbox SharedInbox[T]) Handle(activity streams.Document) error {
+
+	const location = "hannibal.router.SharedInbox.Handle"
+
+	recipients, err := inbox.Recipients(activity)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to find local recipients", activity.ID())
+	}
+
+	if len(recipients) == 0 {
+		log.Trace().Str("activity", activity.ID()).Msg("Hannibal Router: shared inbox activity has no local recipients")
+		return nil
+	}
+
+	var result error
+
+	for _, recipientID := range recipients {
+
+		if err := inbox.handleRecipient(recipientID, activity); (err != nil) && (result == nil) {
+			result = derp.Wrap(err, location, "Unable to handle activity for local recipient", activity.ID(), "recipient: "+recipientID)
+		}
+	}
+
+	return result
+}
+
+// Recipients returns the IDs of every local actor that an activity concerns, without duplicates.
+// The sender's followers are looked up only if the activity is addressed to something that is
+// not local, which may load the sender's actor document to find its followers collection.
+func (inbox SharedInbox[T]) Recipients(activity streams.Document) ([]string, error) {
+
+	const location = "hannibal.router.SharedInbox.Recipients"
+
+	result := make([]string, 0)
+
+	add := func(id string) {
+		if (id != "") && !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+
+	// Find local actors in the addressing fields
+	addressees := activity.Recipients()
+
+	for audience := range activity.Get(vocab.PropertyAudience).RangeIDs() {
+		addressees = append(addressees, audience)
+	}
+
+	hasRemote := false
+
+	for _, addressee := range addressees {
+
+		switch inbox.owner(addressee) {
+
+		case addressee:
+			add(addressee)
+
+		case "":
+			hasRemote = true
+		}
+	}
+
+	// Find local actors that are mentioned by the activity, or by its embedded object
+	mentions := activity.RangeMentions()
+
+	if object := activity.Object(); object.IsMap() {
+		mentions = ranges.Join(mentions, object.RangeMentions())
+	}
+
+	for mention := range mentions {
+		if inbox.owner(mention) == mention {
+			add(mention)
+		}
+	}
+
+	// Find local followers of the sender, if the activity is addressed to the sender's followers
+	if hasRemote && (inbox.followers != nil) {
+
+		if followersID := activity.Actor().Followers().ID(); (followersID != "") && slices.Contains(addressees, followersID) {
+
+			followers, err := inbox.followers(activity.ActorID())
+
+			if err != nil {
+				return nil, derp.Wrap(err, location, "Unable to find local followers", activity.ActorID())
+			}
+
+			for _, follower := range followers {
+				add(follower)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// handleRecipient routes an activity to the appropriate handler for a single local recipient
+func (inbox SharedInbox[T]) handleRecipient(recipientID string, activity streams.Document) error {
+
+	const location = "hannibal.router.SharedInbox.handleRecipient"
+
+	context, err := inbox.contextFunc(recipientID)
+
+	if err != nil {
+		return derp.Wrap(err, location, "Unable to build context for local recipient")
+	}
+
+	// Each recipient receives its own copy (so that handlers cannot change it for later
+	// recipients), which carries the recipient's ID
+	activity = activity.Clone()
+	activity.WithOptions(withRecipientID(activity.RequestContext(), recipientID))
+
+	if err := inbox.router.Handle(context, activity); (err != nil) && !IsDuplicateError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// recipientKey is the context key for the local recipient of a shared inbox activity
+type recipientKey struct{}
+
+// withRecipientID returns a DocumentOption that records the local recipient in a document's request context
+func withRecipientID(ctx context.Context, recipientID string) streams.DocumentOption {
+	return streams.WithRequestContext(context.WithValue(ctx, recipientKey{}, recipientID))
+}
+
+// SetRecipientID records the local actor that owns a personal inbox in the request's context.
+// Call it before receiving the activity (for instance, in an HTTPContextFunc) so that handlers
+// and the Dedup middleware treat the delivery the same way as one for the same recipient
+// through a SharedInbox.
+func SetRecipientID(request *http.Request, recipientID string) {
+	*request = *request.WithContext(context.WithValue(request.Context(), recipientKey{}, recipientID))
+}
+
+// RecipientID returns the local actor that an activity is being handled for: the recipient
+// that a SharedInbox is handling it for, the recipient of a queued activity, or the recipient
+// set with SetRecipientID. It returns an empty string if the recipient is unknown.
+func RecipientID(activity streams.Document) string {
+	recipientID, _ := activity.RequestContext().Value(recipientKey{}).(string)
+	return recipientID
+}
@@ -0,0 +1,196 @@
+package router
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/validator"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/turbine/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// localActor is an OwnerFunc where every actor on https://local.example owns itself
+func localActor(id string) string {
+	if strings.HasPrefix(id, "https://local.example/users/") && !strings.Contains(id, "/followers") {
+		return id
+	}
+	return ""
+}
+
+// bobsFollowers is a FollowersFunc where only bob has local followers
+func bobsFollowers(actorID string) ([]string, error) {
+	if actorID == "https://remote.example/users/bob" {
+		return []string{"https://local.example/users/carol", "https://local.example/users/alice"}, nil
+	}
+	return nil, nil
+}
+
+// sharedInboxActivity returns a Create from bob, with an embedded actor so that nothing is loaded
+func sharedInboxActivity(to ...any) streams.Document {
+	return streams.NewDocument(map[string]any{
+		vocab.PropertyID:   "https://remote.example/activities/1",
+		vocab.PropertyType: vocab.ActivityTypeCreate,
+		vocab.PropertyActor: map[string]any{
+			vocab.PropertyID:        "https://remote.example/users/bob",
+			vocab.PropertyFollowers: "https://remote.example/users/bob/followers",
+		},
+		vocab.PropertyTo: to,
+		vocab.PropertyCC: "https://local.example/users/alice",
+		vocab.PropertyObject: map[string]any{
+			vocab.PropertyType: vocab.ObjectTypeNote,
+			vocab.PropertyTag: []any{
+				map[string]any{vocab.PropertyType: vocab.LinkTypeMention, vocab.PropertyHref: "https://local.example/users/dave"},
+				map[string]any{vocab.PropertyType: vocab.LinkTypeMention, vocab.PropertyHref: "https://remote.example/users/erin"},
+			},
+		},
+	})
+}
+
+// newTestSharedInbox returns a SharedInbox whose context is the recipient's ID
+func newTestSharedInbox(router *Router[string]) SharedInbox[string] {
+	return NewSharedInbox(router, localActor, bobsFollowers, func(recipientID string) (string, error) {
+		return recipientID, nil
+	})
+}
+
+// TestSharedInbox_Recipients confirms local recipients are found in every addressing field, without duplicates.
+func TestSharedInbox_Recipients(t *testing.T) {
+
+	router := New[string]()
+	inbox := newTestSharedInbox(&router)
+
+	// Addressed to bob's followers, and to alice in both "to" and "cc"
+	recipients, err := inbox.Recipients(sharedInboxActivity("https://local.example/users/alice", "https://remote.example/users/bob/followers"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://local.example/users/alice",
+		"https://local.example/users/dave",
+		"https://local.example/users/carol",
+	}, recipients)
+
+	// Not addressed to bob's followers, so they are not included
+	recipients, err = inbox.Recipients(sharedInboxActivity("https://remote.example/users/erin"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://local.example/users/alice", "https://local.example/users/dave"}, recipients)
+}
+
+// TestSharedInbox_Handle confirms the route handler runs once per local recipient, and that
+// Dedup middleware tracks each recipient separately.
+func TestSharedInbox_Handle(t *testing.T) {
+
+	handled := make([]string, 0)
+
+	router := New[string]()
+	router.Use(Dedup[string](NewMemorySeenStore(), time.Hour))
+	router.Add(vocab.ActivityTypeCreate, vocab.Any, func(context string, activity streams.Document) error {
+		assert.Equal(t, context, RecipientID(activity))
+		handled = append(handled, context)
+		return nil
+	})
+
+	inbox := newTestSharedInbox(&router)
+	activity := sharedInboxActivity("https://remote.example/users/bob/followers")
+
+	require.NoError(t, inbox.Handle(activity))
+	assert.Equal(t, []string{
+		"https://local.example/users/alice",
+		"https://local.example/users/dave",
+		"https://local.example/users/carol",
+	}, handled)
+
+	// Retries are duplicates for every recipient
+	require.NoError(t, inbox.Handle(activity))
+	assert.Len(t, handled, 3)
+
+	// Activities outside a SharedInbox have no recipient
+	assert.Empty(t, RecipientID(activity))
+}
+
+// TestSharedInbox_SeparateCopies confirms a handler that changes the activity does not change
+// it for the recipients that are handled after it.
+func TestSharedInbox_SeparateCopies(t *testing.T) {
+
+	names := make([]string, 0)
+
+	router := New[string]()
+	router.Add(vocab.ActivityTypeCreate, vocab.Any, func(context string, activity streams.Document) error {
+		names = append(names, activity.Name())
+		activity.SetProperty(vocab.PropertyName, "changed by "+context)
+		return nil
+	})
+
+	inbox := newTestSharedInbox(&router)
+	activity := sharedInboxActivity()
+
+	require.NoError(t, inbox.Handle(activity))
+	assert.Equal(t, []string{"", ""}, names)
+	assert.Empty(t, activity.Name())
+}
+
+// TestSharedInbox_PersonalDelivery confirms an activity delivered to a personal inbox and to the
+// shared inbox is handled only once for each recipient.
+func TestSharedInbox_PersonalDelivery(t *testing.T) {
+
+	handled := make([]string, 0)
+
+	router := New[string]()
+	router.Use(Dedup[string](NewMemorySeenStore(), time.Hour))
+	router.Add(vocab.ActivityTypeCreate, vocab.Any, func(context string, activity streams.Document) error {
+		handled = append(handled, context)
+		return nil
+	})
+
+	activity := sharedInboxActivity()
+	body, err := json.Marshal(activity.Value())
+	require.NoError(t, err)
+
+	// Delivered to alice's personal inbox
+	request := newActivityRequest(string(body))
+	SetRecipientID(request, "https://local.example/users/alice")
+	require.NoError(t, router.ReceiveAndHandle("https://local.example/users/alice", request, streams.NewDefaultClient(), WithValidators(stubValidator{validator.ResultValid})))
+
+	// Queued for dave's personal inbox
+	args := map[string]any{"recipient": "https://local.example/users/dave", "activity": activity.Value()}
+	result := router.Consumer(func(recipientID string) (string, error) { return recipientID, nil }, streams.NewDefaultClient())(InboxHandleActivity, args)
+	require.Equal(t, queue.ResultStatusSuccess, result.Status)
+
+	// Delivered to the shared inbox as well, which has nothing left to do
+	require.NoError(t, newTestSharedInbox(&router).Handle(activity))
+	assert.Equal(t, []string{"https://local.example/users/alice", "https://local.example/users/dave"}, handled)
+}
+
+// TestSharedInbox_Errors confirms every recipient is handled even when one fails.
+func TestSharedInbox_Errors(t *testing.T) {
+
+	handled := make([]string, 0)
+
+	router := New[string]()
+	router.Add(vocab.ActivityTypeCreate, vocab.Any, func(context string, activity streams.Document) error {
+		handled = append(handled, context)
+		if context == "https://local.example/users/alice" {
+			return derp.Internal("test", "alice's database is down")
+		}
+		return nil
+	})
+
+	inbox := newTestSharedInbox(&router)
+
+	err := inbox.Handle(sharedInboxActivity())
+	require.Error(t, err)
+	assert.Equal(t, []string{"https://local.example/users/alice", "https://local.example/users/dave"}, handled)
+
+	// Errors from the FollowersFunc are returned before any handlers run
+	failing := NewSharedInbox(&router, localActor, func(string) ([]string, error) {
+		return nil, derp.Internal("test", "followers are unavailable")
+	}, inbox.contextFunc)
+
+	handled = handled[:0]
+	require.Error(t, failing.Handle(sharedInboxActivity("https://remote.example/users/bob/followers")))
+	assert.Empty(t, handled)
+}
@@ -11,6 +11,9 @@ import (
 // received in an actor's inbox is valid or not.  Multiple validators can be stacked
 // to validate a document, so if one validator returns `false`, the document is not
 // necessary invalid.  It just can't be validated by this one validator.
+//
+// Validators that load remote documents should pass request.Context() along with
+// them, so that those requests are cancelled if the peer disconnects.
 type Validator interface {
 
 	// Validate checks incoming HTTP requests for validity.  If a document is
@@ -9,3 +9,12 @@ const OutboxSendToAllRecipients = "Outbox:SendToAllRecipients"
 // OutboxSendToSingleRecipient is the name of the task that sends
 // an outbound ActivityPub activity to a single recipient.
 const OutboxSendToSingleRecipient = "Outbox:SendToSingleRecipient"
+
+// OutboxForwardToAllRecipients is the name of the task that resolves
+// the local collections that an inbound activity is being forwarded to,
+// then queues additional tasks to forward it to each member's inbox.
+const OutboxForwardToAllRecipients = "Outbox:ForwardToAllRecipients"
+
+// OutboxForwardToSingleRecipient is the name of the task that forwards
+// the original body of an inbound ActivityPub activity to a single recipient.
+const OutboxForwardToSingleRecipient = "Outbox:ForwardToSingleRecipient"
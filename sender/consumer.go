@@ -19,6 +19,14 @@ func Consumer(sender Sender) queue.Consumer {
 		// Send an activity to a single recipient
 		case OutboxSendToSingleRecipient:
 			return sender.SendToSingleRecipient(args)
+
+		// Catalog all members of forwarded collections and queue individual forward tasks
+		case OutboxForwardToAllRecipients:
+			return sender.ForwardToAllRecipients(args)
+
+		// Forward an inbound activity to a single recipient
+		case OutboxForwardToSingleRecipient:
+			return sender.ForwardToSingleRecipient(args)
 		}
 
 		// All other task names are left for other consumers.
@@ -0,0 +1,132 @@
+package sender
+
+import (
+	"iter"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/remote"
+	"github.com/benpate/rosetta/convert"
+	"github.com/benpate/rosetta/mapof"
+	"github.com/benpate/rosetta/ranges"
+	"github.com/benpate/turbine/queue"
+	"github.com/rs/zerolog/log"
+)
+
+// Forward queues a new task to re-deliver an inbound activity to every member of the
+// provided (local) collections, per ActivityPub section 7.1.2 "Forwarding from Inbox".
+//
+// The body is sent exactly as it was received, so that any Linked Data Signature or
+// Object Integrity Proof inside it can still be verified by the recipients. Each request
+// is signed (HTTP Signatures) by the provided local Actor, who is doing the forwarding.
+// IMPORTANT: The queue.Consumer in this package MUST be connected to a live
+// queue process in order for forwarded activities to be sent.
+func (sender Sender) Forward(actorID string, body []byte, collections ...string) error {
+
+	const location = "hannibal.sender.Forward"
+
+	// NILCHECK: If the Outbox was not properly initialized with a queue, then report an error and return
+	if sender.queue == nil {
+		return derp.Internal(
+			location,
+			"Message cannot be forwarded because the background queue was not provided.",
+			"This should never happen.",
+		)
+	}
+
+	// RULE: There must be something to forward
+	if len(body) == 0 {
+		return derp.BadRequest(location, "Forwarded activity must not be empty")
+	}
+
+	// RULE: There must be someplace to forward it to
+	if len(collections) == 0 {
+		return nil
+	}
+
+	// Queue a new task to forward this activity to all members of the collections
+	task := queue.NewTask(OutboxForwardToAllRecipients, mapof.Any{
+		"actor":      actorID,
+		"body":       string(body),
+		"recipients": collections,
+	})
+
+	if err := sender.queue.Publish(task); err != nil {
+		return derp.Wrap(err, location, "Unable to enqueue forwarded activity", "actorID: "+actorID)
+	}
+
+	// Success!
+	return nil
+}
+
+// ForwardToAllRecipients resolves every collection that an inbound activity is being forwarded
+// to into inbox URLs, then enqueues a separate ForwardToSingleRecipient task for each one.
+func (sender *Sender) ForwardToAllRecipients(args mapof.Any) queue.Result {
+
+	const location = "hannibal.sender.ForwardToAllRecipients"
+
+	// Collect arguments
+	actorID := convert.String(args["actor"])
+	body := convert.String(args["body"])
+
+	// Locate the Actor that is forwarding this activity
+	actor, err := sender.locator.Actor(actorID)
+
+	if err != nil {
+		return queue.Failure(derp.Wrap(err, location, "Unable to locate actor", "actorID: "+actorID))
+	}
+
+	// Use the Locator to resolve each collection into inbox URLs
+	iterators := make([]iter.Seq[string], 0)
+
+	for _, collection := range convert.SliceOfString(args["recipients"]) {
+
+		iterator, err := sender.locator.Recipient(collection)
+
+		if err != nil {
+			return queue.Error(derp.Wrap(err, location, "Unable to resolve recipient for url", collection))
+		}
+
+		iterators = append(iterators, iterator)
+	}
+
+	// Enqueue additional tasks to forward this activity to each (unique) inbox URL
+	for inbox := range ranges.Unique(ranges.Join(iterators...)) {
+
+		// Skip empty inbox URLs
+		if inbox == "" {
+			continue
+		}
+
+		log.Debug().Str("actorID", actorID).Str("recipient", inbox).Msg("Queueing forwarded activity")
+
+		task := queue.NewTask(OutboxForwardToSingleRecipient, mapof.Any{
+			"actor": actor.ActorID(),
+			"inbox": inbox,
+			"body":  body,
+		})
+
+		if err := sender.queue.Publish(task); err != nil {
+			return queue.Error(derp.Wrap(err, location, "Unable to enqueue forwarded activity", "recipient", inbox))
+		}
+	}
+
+	// Task Succeeded Successfully!
+	return queue.Success()
+}
+
+// ForwardToSingleRecipient forwards the original body of an inbound ActivityPub activity
+// to a single recipient's inbox URL, signed by the provided (local) Actor.
+func (sender *Sender) ForwardToSingleRecipient(args mapof.Any) queue.Result {
+
+	const location = "hannibal.sender.ForwardToSingleRecipient"
+
+	// Collect arguments
+	actorID := convert.String(args["actor"])
+	inboxURL := convert.String(args["inbox"])
+	body := convert.String(args["body"])
+
+	log.Debug().Str("actorID", actorID).Str("inboxURL", inboxURL).Msg("Forwarding inbound activity")
+
+	// Send the original bytes, unchanged
+	return sender.deliver(location, actorID, remote.Post(inboxURL).Body(body))
+}
@@ -0,0 +1,155 @@
+package sender
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/benpate/hannibal/router"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/rosetta/mapof"
+	"github.com/benpate/turbine/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that Sender can forward activities for the router's Forwarder
+var _ router.ForwardSender = Sender{}
+
+// TestSender_Forward confirms Forward enqueues a single "forward to all recipients"
+// task that carries the original body unchanged.
+func TestSender_Forward(t *testing.T) {
+
+	q, recorder := newRecordingQueue()
+	sender := New(testLocator{}, q)
+
+	body := []byte(`{"type":"Create",  "id":"https://remote.example/1"}`)
+	require.NoError(t, sender.Forward("https://test.actor.social", body, "https://test.actor.social/followers"))
+
+	require.Equal(t, []string{OutboxForwardToAllRecipients}, recorder.names())
+	assert.Equal(t, string(body), recorder.tasks[0].Arguments.GetString("body"))
+}
+
+// TestSender_Forward_NothingToDo confirms Forward rejects empty bodies, and
+// does not enqueue anything when there are no collections to forward to.
+func TestSender_Forward_NothingToDo(t *testing.T) {
+
+	q, recorder := newRecordingQueue()
+	sender := New(testLocator{}, q)
+
+	require.Error(t, sender.Forward("https://test.actor.social", nil, "https://test.actor.social/followers"))
+	require.NoError(t, sender.Forward("https://test.actor.social", []byte(`{}`)))
+	assert.Empty(t, recorder.names())
+
+	// A Sender without a queue cannot forward anything
+	require.Error(t, Sender{locator: testLocator{}}.Forward("https://test.actor.social", []byte(`{}`), "https://test.actor.social/followers"))
+}
+
+// TestForwardToAllRecipients confirms the forwarded body is fanned out into one
+// "forward to single recipient" task per (deduplicated) member inbox.
+func TestForwardToAllRecipients(t *testing.T) {
+
+	q, recorder := newRecordingQueue()
+	sender := New(testLocator{}, q)
+
+	result := sender.ForwardToAllRecipients(mapof.Any{
+		"actor": "https://test.actor.social",
+		"body":  `{"type":"Create"}`,
+		// The followers collection is listed twice, but each inbox is only sent once
+		"recipients": []string{"https://test.actor.social/followers", "https://test.actor.social/followers"},
+	})
+	require.Equal(t, queue.ResultStatusSuccess, result.Status)
+
+	names := recorder.names()
+	assert.Len(t, names, 3)
+	for index, name := range names {
+		assert.Equal(t, OutboxForwardToSingleRecipient, name)
+		assert.Equal(t, `{"type":"Create"}`, recorder.tasks[index].Arguments.GetString("body"))
+	}
+}
+
+// TestForwardToAllRecipients_Errors confirms an unknown actor yields a Failure,
+// and a failure to resolve recipients yields a (retriable) Error.
+func TestForwardToAllRecipients_Errors(t *testing.T) {
+
+	q, _ := newRecordingQueue()
+
+	sender := New(testLocator{}, q)
+	unknownActor := sender.ForwardToAllRecipients(mapof.Any{
+		"actor":      "https://unknown.example.com/actor",
+		"body":       `{}`,
+		"recipients": []string{"https://test.actor.social/followers"},
+	})
+	assert.Equal(t, queue.ResultStatusFailure, unknownActor.Status)
+
+	sender = New(erroringLocator{}, q)
+	unresolved := sender.ForwardToAllRecipients(mapof.Any{
+		"actor":      "https://test.actor.social",
+		"body":       `{}`,
+		"recipients": []string{"https://test.actor.social/followers"},
+	})
+	assert.Equal(t, queue.ResultStatusError, unresolved.Status)
+}
+
+// TestForwardToSingleRecipient confirms the original bytes are POSTed to the
+// recipient inbox unchanged, and signed by the forwarding actor.
+func TestForwardToSingleRecipient(t *testing.T) {
+
+	sender, actorID := newKeyedSender(t)
+
+	// Unusual spacing and key order would not survive a re-encoding
+	body := `{"type":"Create",   "actor":"https://remote.example/users/bob","signature":{"signatureValue":"abc"}}`
+
+	var mutex sync.Mutex
+	var received string
+	var header http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		bytes, _ := io.ReadAll(r.Body)
+		received = string(bytes)
+		header = r.Header.Clone()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	result := sender.ForwardToSingleRecipient(mapof.Any{
+		"actor": actorID,
+		"inbox": server.URL,
+		"body":  body,
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	assert.Equal(t, queue.ResultStatusSuccess, result.Status)
+	assert.Equal(t, body, received)
+	assert.Equal(t, vocab.ContentTypeActivityPub, header.Get("Content-Type"))
+	assert.NotEmpty(t, header.Get("Signature"), "the forwarded request must be signed")
+	assert.NotEmpty(t, header.Get("Digest"), "the forwarded request must carry a body digest")
+}
+
+// TestConsumer_Forward confirms the queue Consumer routes forwarding tasks to the Sender.
+func TestConsumer_Forward(t *testing.T) {
+
+	q, recorder := newRecordingQueue()
+	consumer := Consumer(New(testLocator{}, q))
+
+	allResult := consumer(OutboxForwardToAllRecipients, mapof.Any{
+		"actor":      "https://test.actor.social",
+		"body":       `{}`,
+		"recipients": []string{"https://test.actor.social/"},
+	})
+	assert.Equal(t, queue.ResultStatusSuccess, allResult.Status)
+	assert.Equal(t, []string{OutboxForwardToSingleRecipient}, recorder.names())
+
+	singleResult := consumer(OutboxForwardToSingleRecipient, mapof.Any{
+		"actor": "https://unknown.example.com/actor",
+		"inbox": "https://example.com/inbox",
+		"body":  `{}`,
+	})
+	assert.NotEqual(t, queue.ResultStatusIgnored, singleResult.Status)
+}
@@ -152,6 +152,14 @@ func (sender *Sender) SendToSingleRecipient(args mapof.Any) queue.Result {
 
 	log.Debug().Str("actorID", actorID).Str("inboxURL", inboxURL).Msg("Sending outbound activity")
 
+	// Send the activity as JSON
+	return sender.deliver(location, actorID, remote.Post(inboxURL).JSON(activity))
+}
+
+// deliver signs and sends a transaction (a POST to a recipient's inbox) as the provided Actor,
+// and converts the outcome into a queue.Result.
+func (sender *Sender) deliver(location string, actorID string, transaction *remote.Transaction) queue.Result {
+
 	// Locate the Actor that is sending this activity
 	actor, err := sender.locator.Actor(actorID)
 
@@ -160,11 +168,10 @@ func (sender *Sender) SendToSingleRecipient(args mapof.Any) queue.Result {
 	}
 
 	// Prepare a transaction to send to target Actor's inbox
-	transaction := remote.Post(inboxURL).
+	transaction.
 		Accept(vocab.ContentTypeActivityPub).
 		ContentType(vocab.ContentTypeActivityPub).
-		With(signRequest(actor.PrivateKey())).
-		JSON(activity)
+		With(signRequest(actor.PrivateKey()))
 
 	// RULE: By default, remote refuses to connect to non-public (private/loopback)
 	// addresses to guard against SSRF. sender.allowPrivateIPs stays FALSE in production;
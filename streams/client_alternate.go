@@ -0,0 +1,308 @@
+package streams
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/remote"
+	"golang.org/x/net/html"
+)
+
+// maxAlternateBodySize is the largest HTML page that will be searched for an ActivityPub alternate
+const maxAlternateBodySize = 1 << 20 // 1 MB
+
+// activityStreamsProfile is the JSON-LD profile that identifies an ActivityStreams
+// document (without its scheme, because both "http" and "https" are in use)
+const activityStreamsProfile = "www.w3.org/ns/activitystreams"
+
+// alternateLink is a single link from an HTML page or a "Link" header
+type alternateLink struct {
+	href      string
+	rel       string
+	mediaType string
+}
+
+// discoverAlternate returns a remote.Option that looks for the ActivityPub version of a
+// document when a server responds with an HTML web page instead. It checks the "Link" header,
+// then any <link rel="alternate"> tags in the HTML, and writes the first ActivityPub URL that
+// it finds into `alternate`. HTML responses always stop the transaction with an error, so
+// they are never parsed as JSON.
+func discoverAlternate(alternate *string) remote.Option {
+
+	const location = "hannibal.streams.discoverAlternate"
+
+	return remote.Option{
+
+		AfterRequest: func(_ *remote.Transaction, response *http.Response) error {
+
+			if response == nil {
+				return nil
+			}
+
+			contentType := response.Header.Get(vocab.ContentType)
+
+			// Everything other than a successful HTML response is loaded normally
+			if !isHTMLMediaType(contentType) {
+				return nil
+			}
+
+			if (response.StatusCode < 200) || (response.StatusCode > 299) {
+				return nil
+			}
+
+			links := parseLinkHeader(response.Header.Values("Link"))
+
+			if response.Body != nil {
+				links = append(links, parseHTMLLinks(io.LimitReader(response.Body, maxAlternateBodySize))...)
+			}
+
+			for _, link := range links {
+
+				if !link.isActivityPubAlternate() {
+					continue
+				}
+
+				if href, ok := resolveLink(response, link.href); ok {
+					*alternate = href
+					return derp.BadRequest(location, "Document is HTML. Loading ActivityPub alternate instead", href)
+				}
+			}
+
+			return derp.BadRequest(location, "Document is HTML, and does not link to an ActivityPub alternate", contentType)
+		},
+	}
+}
+
+// isActivityPubAlternate returns TRUE if this link points to the ActivityPub version of a page
+func (link alternateLink) isActivityPubAlternate() bool {
+
+	if link.href == "" {
+		return false
+	}
+
+	if !containsToken(link.rel, "alternate") {
+		return false
+	}
+
+	return isActivityPubMediaType(link.mediaType)
+}
+
+// isActivityPubMediaType returns TRUE for "application/activity+json", and for
+// "application/ld+json" with the ActivityStreams profile
+func isActivityPubMediaType(value string) bool {
+
+	mediaType, parameters, err := mime.ParseMediaType(value)
+
+	if err != nil {
+		return false
+	}
+
+	switch mediaType {
+
+	case vocab.ContentTypeActivityPub:
+		return true
+
+	case vocab.ContentTypeJSONLD:
+		// The profile parameter is a space-separated list of URIs
+		for _, profile := range strings.Fields(parameters["profile"]) {
+			if strings.TrimPrefix(strings.TrimPrefix(profile, "https://"), "http://") == activityStreamsProfile {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isHTMLMediaType returns TRUE for HTML (and XHTML) media types
+func isHTMLMediaType(value string) bool {
+
+	mediaType, _, err := mime.ParseMediaType(value)
+
+	if err != nil {
+		return false
+	}
+
+	return (mediaType == vocab.ContentTypeHTML) || (mediaType == "application/xhtml+xml")
+}
+
+// parseHTMLLinks returns every <link> tag in an HTML document
+func parseHTMLLinks(body io.Reader) []alternateLink {
+
+	result := make([]alternateLink, 0)
+	tokenizer := html.NewTokenizer(body)
+
+	for {
+		switch tokenizer.Next() {
+
+		case html.ErrorToken:
+			return result
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+
+			name, hasAttributes := tokenizer.TagName()
+
+			if (string(name) != "link") || !hasAttributes {
+				continue
+			}
+
+			link := alternateLink{}
+
+			for {
+				key, value, more := tokenizer.TagAttr()
+
+				switch string(key) {
+				case "href":
+					link.href = string(value)
+				case "rel":
+					link.rel = string(value)
+				case "type":
+					link.mediaType = string(value)
+				}
+
+				if !more {
+					break
+				}
+			}
+
+			result = append(result, link)
+		}
+	}
+}
+
+// parseLinkHeader returns every link in a list of HTTP "Link" headers (RFC 8288), such as:
+// <https://example.com/1>; rel="alternate"; type="application/activity+json"
+func parseLinkHeader(values []string) []alternateLink {
+
+	result := make([]alternateLink, 0)
+
+	for _, value := range values {
+		for _, entry := range splitOutsideQuotes(value, ',') {
+
+			parameters := splitOutsideQuotes(entry, ';')
+
+			if len(parameters) == 0 {
+				continue
+			}
+
+			href := strings.TrimSpace(parameters[0])
+
+			if !strings.HasPrefix(href, "<") || !strings.HasSuffix(href, ">") {
+				continue
+			}
+
+			link := alternateLink{href: href[1 : len(href)-1]}
+
+			for _, parameter := range parameters[1:] {
+
+				name, value, _ := strings.Cut(parameter, "=")
+				value = unquote(value)
+
+				switch strings.ToLower(strings.TrimSpace(name)) {
+				case "rel":
+					link.rel = value
+				case "type":
+					link.mediaType = value
+				}
+			}
+
+			result = append(result, link)
+		}
+	}
+
+	return result
+}
+
+// splitOutsideQuotes splits a header value on a separator, ignoring separators
+// that are inside quoted strings or <angle brackets>
+func splitOutsideQuotes(value string, separator rune) []string {
+
+	result := make([]string, 0)
+	quoted := false
+	escaped := false
+	bracketed := false
+	start := 0
+
+	for index, character := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case character == '\\' && quoted:
+			escaped = true
+		case character == '"' && !bracketed:
+			quoted = !quoted
+		case character == '<' && !quoted:
+			bracketed = true
+		case character == '>' && !quoted:
+			bracketed = false
+		case character == separator && !quoted && !bracketed:
+			result = append(result, value[start:index])
+			start = index + 1
+		}
+	}
+
+	return append(result, value[start:])
+}
+
+// unquote removes the quotes (and backslash escapes) from an HTTP quoted-string.
+// Values that are not quoted are returned as-is, without surrounding whitespace.
+func unquote(value string) string {
+
+	value = strings.TrimSpace(value)
+
+	if (len(value) < 2) || (value[0] != '"') || (value[len(value)-1] != '"') {
+		return value
+	}
+
+	var result strings.Builder
+	escaped := false
+
+	for _, character := range value[1 : len(value)-1] {
+		if !escaped && (character == '\\') {
+			escaped = true
+			continue
+		}
+		escaped = false
+		result.WriteRune(character)
+	}
+
+	return result.String()
+}
+
+// containsToken returns TRUE if a space-separated list (like an HTML "rel" attribute) includes a token
+func containsToken(list string, token string) bool {
+
+	for _, item := range strings.Fields(list) {
+		if strings.EqualFold(item, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveLink converts a (possibly relative) link into an absolute HTTP(S) URL,
+// using the URL that the response was actually loaded from.
+func resolveLink(response *http.Response, href string) (string, bool) {
+
+	reference, err := url.Parse(strings.TrimSpace(href))
+
+	if err != nil {
+		return "", false
+	}
+
+	if response.Request != nil && response.Request.URL != nil {
+		reference = response.Request.URL.ResolveReference(reference)
+	}
+
+	if (reference.Scheme != "http") && (reference.Scheme != "https") {
+		return "", false
+	}
+
+	return reference.String(), true
+}
@@ -0,0 +1,162 @@
+package streams
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benpate/hannibal/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAlternateServer returns a test server with an HTML page at "/@alice" and its ActivityPub
+// version at "/users/alice". The `page` function writes the HTML page.
+func newAlternateServer(t *testing.T, page func(w http.ResponseWriter)) *httptest.Server {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/@alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page(w)
+	})
+
+	mux.HandleFunc("/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", vocab.ContentTypeJSONLDWithProfile)
+		_, _ = w.Write([]byte(`{"id":"https://example.com/users/alice","type":"Person"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestDefaultClient_Load_HTMLAlternate confirms an HTML page is replaced by its <link rel="alternate">.
+func TestDefaultClient_Load_HTMLAlternate(t *testing.T) {
+
+	server := newAlternateServer(t, func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html><html><head>
+			<link rel="stylesheet" href="/style.css">
+			<link rel="alternate" type="application/rss+xml" href="/@alice.rss">
+			<link href="/users/alice" rel="alternate" type="application/activity+json">
+		</head><body>Hello</body></html>`))
+	})
+
+	document, err := NewDefaultClient(allowPrivateIPs()).Load(server.URL + "/@alice")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/users/alice", document.ID())
+}
+
+// recordingClient is a Client wrapper that records every URL loaded through it
+type recordingClient struct {
+	innerClient Client
+	urls        []string
+}
+
+func (client *recordingClient) SetRootClient(rootClient Client) {
+	client.innerClient.SetRootClient(rootClient)
+}
+
+func (client *recordingClient) Load(uri string, options ...any) (Document, error) {
+	client.urls = append(client.urls, uri)
+	return client.innerClient.Load(uri, options...)
+}
+
+func (client *recordingClient) Save(document Document) error {
+	return client.innerClient.Save(document)
+}
+
+func (client *recordingClient) Delete(documentID string) error {
+	return client.innerClient.Delete(documentID)
+}
+
+// TestDefaultClient_Load_AlternateUsesRootClient confirms the alternate is loaded through
+// the root client, so that wrappers around the DefaultClient see it too.
+func TestDefaultClient_Load_AlternateUsesRootClient(t *testing.T) {
+
+	server := newAlternateServer(t, func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte(`<link href="/users/alice" rel="alternate" type="application/activity+json">`))
+	})
+
+	client := &recordingClient{innerClient: NewDefaultClient(allowPrivateIPs())}
+	client.SetRootClient(client)
+
+	document, err := client.Load(server.URL + "/@alice")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/users/alice", document.ID())
+	assert.Equal(t, []string{server.URL + "/@alice", server.URL + "/users/alice"}, client.urls)
+}
+
+// TestDefaultClient_Load_LinkHeader confirms an HTML page is replaced by the alternate in its "Link" header,
+// including the "application/ld+json" variant with the ActivityStreams profile.
+func TestDefaultClient_Load_LinkHeader(t *testing.T) {
+
+	var server *httptest.Server
+	server = newAlternateServer(t, func(w http.ResponseWriter) {
+		w.Header().Set("Link", `<`+server.URL+`/feed>; rel="alternate"; type="application/rss+xml", <`+server.URL+`/users/alice>; rel="alternate"; type="application/ld+json; profile=\"https://www.w3.org/ns/activitystreams\""`)
+		_, _ = w.Write([]byte(`<html><body>No links here</body></html>`))
+	})
+
+	document, err := NewDefaultClient(allowPrivateIPs()).Load(server.URL + "/@alice")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/users/alice", document.ID())
+}
+
+// TestDefaultClient_Load_HTMLWithoutAlternate confirms HTML pages without an ActivityPub alternate are errors.
+func TestDefaultClient_Load_HTMLWithoutAlternate(t *testing.T) {
+
+	server := newAlternateServer(t, func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte(`<html><head><link rel="alternate" type="application/ld+json" href="/schema.json"></head></html>`))
+	})
+
+	_, err := NewDefaultClient(allowPrivateIPs()).Load(server.URL + "/@alice")
+	require.Error(t, err)
+}
+
+// TestDefaultClient_Load_AlternateLoop confirms only one alternate is followed.
+func TestDefaultClient_Load_AlternateLoop(t *testing.T) {
+
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<link rel="alternate" type="application/activity+json" href="/again">`))
+	}))
+	defer server.Close()
+
+	_, err := NewDefaultClient(allowPrivateIPs()).Load(server.URL)
+	require.Error(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+// TestIsActivityPubMediaType confirms which link types identify an ActivityPub document.
+func TestIsActivityPubMediaType(t *testing.T) {
+
+	assert.True(t, isActivityPubMediaType("application/activity+json"))
+	assert.True(t, isActivityPubMediaType("Application/Activity+JSON; charset=utf-8"))
+	assert.True(t, isActivityPubMediaType(`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`))
+	assert.True(t, isActivityPubMediaType(`application/ld+json; profile="http://www.w3.org/ns/activitystreams https://example.com/other"`))
+
+	assert.False(t, isActivityPubMediaType("application/ld+json"))
+	assert.False(t, isActivityPubMediaType(`application/ld+json; profile="https://schema.org"`))
+	assert.False(t, isActivityPubMediaType("application/json"))
+	assert.False(t, isActivityPubMediaType("text/html"))
+	assert.False(t, isActivityPubMediaType(""))
+}
+
+// TestParseLinkHeader confirms commas and semicolons inside quotes and URLs do not split links.
+func TestParseLinkHeader(t *testing.T) {
+
+	links := parseLinkHeader([]string{
+		`<https://example.com/a,b>; rel="alternate"; type="application/ld+json; profile=\"https://www.w3.org/ns/activitystreams\"", <https://example.com/c>; rel=next`,
+	})
+
+	require.Len(t, links, 2)
+	assert.Equal(t, "https://example.com/a,b", links[0].href)
+	assert.Equal(t, "alternate", links[0].rel)
+	assert.True(t, strings.HasPrefix(links[0].mediaType, "application/ld+json"))
+	assert.Equal(t, "https://example.com/c", links[1].href)
+	assert.Equal(t, "next", links[1].rel)
+}
@@ -0,0 +1,19 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/benpate/remote"
+)
+
+// ContextOption returns a remote.Option that binds an outbound request to a context.Context,
+// so that the request is cancelled when the context is cancelled or its deadline passes.
+func ContextOption(ctx context.Context) remote.Option {
+	return remote.Option{
+		ModifyRequest: func(_ *remote.Transaction, request *http.Request) *http.Response {
+			*request = *request.WithContext(ctx)
+			return nil
+		},
+	}
+}
@@ -1,6 +1,9 @@
 package streams
 
 import (
+	"context"
+	"slices"
+
 	"github.com/benpate/derp"
 	"github.com/benpate/hannibal/vocab"
 	"github.com/benpate/remote"
@@ -9,32 +12,58 @@ import (
 // DefaultClient is a default implementation of the hannibal.Client interface.
 // It simply loads ActivityStream documents from remote servers with no caching
 type DefaultClient struct {
-	options []remote.Option
+	options    []remote.Option
+	rootClient Client // Top-level client, used to load ActivityPub alternates through the whole client chain
 }
 
 // NewDefaultClient returns a DefaultClient that loads documents from remote servers using the provided options.
 func NewDefaultClient(options ...remote.Option) Client {
-	return DefaultClient{
+
+	result := &DefaultClient{
 		options: options,
 	}
+
+	result.rootClient = result
+	return result
 }
 
+// followedAlternate is a Load option that marks a request for an ActivityPub alternate,
+// so that DefaultClient does not follow another one from it.
+type followedAlternate struct{}
+
 // Load implements the hannibal.Client interface, which loads an ActivityStream
 // document from a remote server. For the hannibal default client, this method
 // simply loads the document from a remote server with no other processing.
-func (client DefaultClient) Load(url string, options ...any) (Document, error) {
+// Any remote.Option values included in the options are applied to this request
+// only, after the options that the client was created with. A context.Context in the
+// options cancels the request along with it. All other options are ignored.
+//
+// If the server responds with an HTML page (as many do for profile and post URLs)
+// then Load follows the page's ActivityPub alternate link, if it has one. The alternate
+// is loaded through the root client, so that every wrapper in the client chain (caches,
+// origin checks, domain filters, and so on) applies to it as well.
+func (client *DefaultClient) Load(url string, options ...any) (Document, error) {
 
 	const location = "hannibal.streams.Client.Load"
 
 	result := make(map[string]any)
+	alternate := ""
 
 	// Try to load-and-parse the value from the remote server
 	transaction := remote.Get(url).
-		Accept(vocab.ContentTypeActivityPub).
+		Accept(vocab.ContentTypeActivityPub, vocab.ContentTypeJSONLDWithProfile).
 		With(client.options...).
+		With(remoteOptions(options)...).
+		With(discoverAlternate(&alternate)).
 		Result(&result)
 
 	if err := transaction.Send(); err != nil {
+
+		// Follow (only one) link from an HTML page to its ActivityPub version
+		if (alternate != "") && !slices.Contains(options, any(followedAlternate{})) {
+			return client.root().Load(alternate, append(options[:len(options):len(options)], followedAlternate{})...)
+		}
+
 		return NilDocument(), derp.Wrap(err, location, "Unable to load JSON-LD document", url)
 	}
 
@@ -46,18 +75,48 @@ func (client DefaultClient) Load(url string, options ...any) (Document, error) {
 		nil
 }
 
+// root returns the top-level client, or this client if none has been set
+func (client *DefaultClient) root() Client {
+
+	if client.rootClient == nil {
+		return client
+	}
+
+	return client.rootClient
+}
+
+// remoteOptions returns the remote.Option values from a list of Load options
+func remoteOptions(options []any) []remote.Option {
+
+	result := make([]remote.Option, 0, len(options))
+
+	for _, option := range options {
+		switch typed := option.(type) {
+
+		case remote.Option:
+			result = append(result, typed)
+
+		case context.Context:
+			result = append(result, ContextOption(typed))
+		}
+	}
+
+	return result
+}
+
 // Save is required to implement the document.Cache interface.
 // For this client, Save is a NOOP
-func (client DefaultClient) Save(document Document) error {
+func (client *DefaultClient) Save(document Document) error {
 	return nil
 }
 
 // Delete is required to implement the document.Cache interface.
 // For this client, Delete is a NOOP
-func (client DefaultClient) Delete(documentID string) error {
+func (client *DefaultClient) Delete(documentID string) error {
 	return nil
 }
 
-// SetRootClient is required to implement the hannibal.Client interface.
-// For this client, SetRootClient is a NOOP
-func (client DefaultClient) SetRootClient(rootClient Client) {}
+// SetRootClient records the top-level client, which is used to load ActivityPub alternates.
+func (client *DefaultClient) SetRootClient(rootClient Client) {
+	client.rootClient = rootClient
+}
@@ -1,6 +1,7 @@
 package streams
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -58,8 +59,40 @@ func TestDefaultClient_Load_Error(t *testing.T) {
 	require.Error(t, err)
 }
 
+// TestDefaultClient_Load_RemoteOptions confirms remote.Options passed to Load are
+// applied to that request, and that other option types are ignored.
+func TestDefaultClient_Load_RemoteOptions(t *testing.T) {
+
+	var received string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Test")
+		w.Header().Set("Content-Type", vocab.ContentTypeActivityPub)
+		_, _ = w.Write([]byte(`{"id":"urn:loaded"}`))
+	}))
+	defer server.Close()
+
+	withHeader := remote.Option{
+		BeforeRequest: func(txn *remote.Transaction) error {
+			txn.Header("X-Test", "per-request")
+			return nil
+		},
+	}
+
+	client := NewDefaultClient(allowPrivateIPs())
+
+	_, err := client.Load(server.URL, "ignored", withHeader)
+	require.NoError(t, err)
+	assert.Equal(t, "per-request", received)
+
+	// Options do not carry over to the next request
+	_, err = client.Load(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "", received)
+}
+
 // TestDefaultClient_SaveDeleteNoop confirms Save and Delete are no-ops that
-// return nil, and SetRootClient does not panic.
+// return nil, and SetRootClient records the client used to load alternates.
 func TestDefaultClient_SaveDeleteNoop(t *testing.T) {
 
 	client := NewDefaultClient()
@@ -68,6 +101,77 @@ func TestDefaultClient_SaveDeleteNoop(t *testing.T) {
 	assert.NoError(t, client.Save(document))
 	assert.NoError(t, client.Delete(document.ID()))
 
-	// SetRootClient is a no-op for the default client; calling it must not panic.
-	assert.NotPanics(t, func() { client.SetRootClient(client) })
+	// The default client is its own root until a wrapper claims it
+	defaultClient := client.(*DefaultClient)
+	assert.Same(t, defaultClient, defaultClient.root())
+
+	root := &recordingClient{innerClient: client}
+	client.SetRootClient(root)
+	assert.Same(t, root, defaultClient.root())
+}
+
+// TestDefaultClient_Load_Context confirms a context.Context passed to Load cancels the request.
+func TestDefaultClient_Load_Context(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", vocab.ContentTypeActivityPub)
+		_, _ = w.Write([]byte(`{"id":"urn:loaded"}`))
+	}))
+	defer server.Close()
+
+	client := NewDefaultClient(allowPrivateIPs())
+
+	// A live context does not interfere with the request
+	_, err := client.Load(server.URL, context.Background())
+	require.NoError(t, err)
+
+	// A cancelled context stops the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = client.Load(server.URL, ctx)
+	require.Error(t, err)
+}
+
+// TestDocument_RequestContext confirms a document's context is used to load it, and is passed along
+// to its properties and to the documents loaded through it.
+func TestDocument_RequestContext(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", vocab.ContentTypeActivityPub)
+		_, _ = w.Write([]byte(`{"id":"urn:loaded","type":"Note"}`))
+	}))
+	defer server.Close()
+
+	client := NewDefaultClient(allowPrivateIPs())
+
+	// Documents without a context use context.Background()
+	assert.Equal(t, context.Background(), NilDocument().RequestContext())
+
+	// The context is shared with properties and loaded documents
+	type contextKey struct{}
+	ctx := context.WithValue(context.Background(), contextKey{}, "inbox")
+
+	activity := NewDocument(map[string]any{
+		vocab.PropertyType:   vocab.ActivityTypeCreate,
+		vocab.PropertyObject: server.URL,
+	}, WithClient(client), WithRequestContext(ctx))
+
+	object, err := activity.Object().Load()
+	require.NoError(t, err)
+	assert.Equal(t, "urn:loaded", object.ID())
+	assert.Equal(t, "inbox", object.RequestContext().Value(contextKey{}))
+	assert.Equal(t, "inbox", activity.Clone().RequestContext().Value(contextKey{}))
+
+	// A cancelled context stops the request
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	activity.WithOptions(WithRequestContext(cancelled))
+	_, err = activity.Object().Load()
+	require.Error(t, err)
+
+	// A context passed to Load takes precedence
+	_, err = activity.Object().Load(context.Background())
+	require.NoError(t, err)
 }
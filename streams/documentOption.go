@@ -1,6 +1,7 @@
 package streams
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/benpate/hannibal/metadata"
@@ -33,3 +34,12 @@ func WithMetadata(value metadata.Metadata) DocumentOption {
 		doc.Metadata = value
 	}
 }
+
+// WithRequestContext attaches a context.Context to the document. Remote requests made to load this
+// document, its properties, and any documents loaded through them use the context, so they
+// are cancelled when it is cancelled or its deadline passes.
+func WithRequestContext(ctx context.Context) DocumentOption {
+	return func(doc *Document) {
+		doc.ctx = ctx
+	}
+}
@@ -1,6 +1,7 @@
 package streams
 
 import (
+	"context"
 	"html"
 	"net/http"
 	"time"
@@ -26,6 +27,7 @@ type Document struct {
 	Metadata   metadata.Metadata
 	httpHeader http.Header
 	client     Client
+	ctx        context.Context
 }
 
 // NewDocument creates a new Document object from a JSON-LD map[string]any
@@ -113,6 +115,7 @@ func (document Document) Clone() Document {
 		httpHeader: document.httpHeader.Clone(),
 		value:      document.value.Clone(),
 		Metadata:   document.Metadata.Clone(),
+		ctx:        document.ctx,
 	}
 }
 
@@ -229,6 +232,12 @@ func (document Document) Load(options ...any) (Document, error) {
 		return NilDocument(), derp.BadRequest(location, "Document ID is not a valid URL", documentID)
 	}
 
+	// Requests made for this document share its context, so they are cancelled along with it.
+	// A context passed in the options is applied later, so it takes precedence.
+	if document.ctx != nil {
+		options = append([]any{document.ctx}, options...)
+	}
+
 	// Try to load the document from the Interwebs
 	result, err := document.getClient().Load(documentID, options...)
 
@@ -236,6 +245,11 @@ func (document Document) Load(options ...any) (Document, error) {
 		return result, derp.Wrap(err, location, "Unable to load document by ID", document.Value())
 	}
 
+	// Documents loaded through this document keep using its context
+	if document.ctx != nil {
+		result.ctx = document.ctx
+	}
+
 	// Success??
 	return result, nil
 }
@@ -414,6 +428,18 @@ func (document Document) AddOptions(options ...DocumentOption) Document {
 	return document
 }
 
+// RequestContext returns the context.Context attached to this document (with WithRequestContext),
+// or context.Background() if there is none. This is unrelated to the ActivityStreams "context"
+// property, which is returned by Context().
+func (document Document) RequestContext() context.Context {
+
+	if document.ctx != nil {
+		return document.ctx
+	}
+
+	return context.Background()
+}
+
 func (document *Document) getClient() Client {
 
 	if document.client != nil {
@@ -429,5 +455,6 @@ func (document *Document) sub(value property.Value) Document {
 		value:      value,
 		client:     document.client,
 		httpHeader: document.httpHeader,
+		ctx:        document.ctx,
 	}
 }
@@ -38,7 +38,8 @@ func (v DeletedObject) Validate(request *http.Request, activity *streams.Documen
 
 	// Try to retrieve the original document
 	txn := remote.Get(objectID).
-		Header("Accept", "application/activity+json")
+		Header("Accept", "application/activity+json").
+		With(streams.ContextOption(request.Context()))
 
 	if err := txn.Send(); err != nil {
 
@@ -29,7 +29,7 @@ func (v HTTPLookup) Validate(request *http.Request, activity *streams.Document)
 	}
 
 	// Get the ObjectID of the document
-	object, err := activity.Object().Load()
+	object, err := activity.Object().Load(request.Context())
 
 	if err != nil {
 		derp.Report(derp.Wrap(err, location, "Loading original document"))
@@ -42,7 +42,7 @@ func (validator HTTPSig) Validate(request *http.Request, activity *streams.Docum
 
 	// If none is provided, then use the default KeyFinder, which looks up the Actor's public key from the document.
 	if keyFinder == nil {
-		keyFinder = defaultKeyFinder(activity)
+		keyFinder = defaultKeyFinder(request, activity)
 	}
 
 	// Verify the request using the Actor's public key
@@ -64,15 +64,15 @@ func (validator HTTPSig) Validate(request *http.Request, activity *streams.Docum
 }
 
 // keyFinder looks up the public Key for the provided activity/Actor using the
-// HTTP client in the activity.
-func defaultKeyFinder(activity *streams.Document) sigs.PublicKeyFinder {
+// HTTP client in the activity. The lookup is cancelled along with the request.
+func defaultKeyFinder(request *http.Request, activity *streams.Document) sigs.PublicKeyFinder {
 
 	const location = "hannibal.validator.defaultKeyFinder"
 
 	return func(keyID string) (string, error) {
 
 		// Create a fresh client to load the Actor from the activity
-		actor, err := streams.NewDocument(activity.Actor().ID()).Load()
+		actor, err := streams.NewDocument(activity.Actor().ID()).Load(request.Context())
 
 		if err != nil {
 			return "", derp.Wrap(err, location, "Retrieving Actor from ActivityPub activity", activity.Value())
@@ -1,5 +1,10 @@
 package vocab
 
+// PropertyAlsoKnownAs is the "alsoKnownAs" actor property, which lists other
+// accounts that belong to the same person (used for account migration).
+// https://www.w3.org/TR/did-core/#also-known-as
+const PropertyAlsoKnownAs = "alsoKnownAs"
+
 // PropertyBlocked is the "blocked" actor property.
 // https://w3id/fep/c648
 const PropertyBlocked = "blocked"
@@ -20,6 +25,11 @@ const PropertyFollowers = "followers"
 // https://www.w3.org/TR/activitypub/#inbox
 const PropertyInbox = "inbox"
 
+// PropertyManuallyApprovesFollowers is the "manuallyApprovesFollowers" actor property,
+// which tells other servers that Follow requests are reviewed by hand.
+// https://docs.joinmastodon.org/spec/activitypub/#as
+const PropertyManuallyApprovesFollowers = "manuallyApprovesFollowers"
+
 // PropertyMLSKeyPackages is the "keyPackages" actor property.
 // https://swicg.github.io/activitypub-e2ee/mls#keyPackages
 const PropertyMLSKeyPackages = "keyPackages"
@@ -0,0 +1,57 @@
+package webfinger
+
+import "github.com/benpate/hannibal/vocab"
+
+// Account describes a local actor to a WebFinger server. It is returned by
+// a LookupFunc, and converted into the Resource that is sent to clients.
+type Account struct {
+	Subject    string   // Canonical "acct:" URI for this account. Defaults to the requested resource
+	ActorID    string   // URL of the ActivityPub actor (required)
+	ProfileURL string   // URL of the actor's human-readable profile page (optional)
+	Aliases    []string // Other URIs for this account. Defaults to the ActorID and ProfileURL
+	Links      []Link   // Additional links, such as avatars or subscription templates (optional)
+}
+
+// LookupFunc maps a requested resource to a local Account. Handles are normalized to
+// "acct:username@host" (with a lowercase host) and URLs are passed as-is. It should
+// return a derp "404 Not Found" error if the account does not exist.
+type LookupFunc func(resource string) (Account, error)
+
+// Resource converts this Account into a WebFinger Resource for the requested resource
+func (account Account) Resource(resource string) Resource {
+
+	result := Resource{
+		Subject: account.Subject,
+		Aliases: account.Aliases,
+		Links: []Link{{
+			Rel:  RelationSelf,
+			Type: vocab.ContentTypeActivityPub,
+			Href: account.ActorID,
+		}},
+	}
+
+	if result.Subject == "" {
+		result.Subject = resource
+	}
+
+	if account.ProfileURL != "" {
+		result.Links = append(result.Links, Link{
+			Rel:  RelationProfilePage,
+			Type: vocab.ContentTypeHTML,
+			Href: account.ProfileURL,
+		})
+	}
+
+	result.Links = append(result.Links, account.Links...)
+
+	// Default aliases are the actor and its profile page
+	if result.Aliases == nil {
+		result.Aliases = []string{account.ActorID}
+
+		if account.ProfileURL != "" {
+			result.Aliases = append(result.Aliases, account.ProfileURL)
+		}
+	}
+
+	return result
+}
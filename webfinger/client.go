@@ -0,0 +1,228 @@
+package webfinger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/internal/httpbody"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/benpate/remote"
+)
+
+// Client is a streams.Client wrapper that resolves fediverse handles. Loading a handle like
+// "@alice@example.social" (or "acct:alice@example.social") looks up the handle with WebFinger,
+// then loads the actor document that it points to from the inner client. All other URLs are
+// passed straight through to the inner client.
+type Client struct {
+	innerClient streams.Client
+	options     []remote.Option // Options applied to every WebFinger and host-meta request
+	scheme      string          // Scheme used to contact remote servers (always "https" outside of tests)
+}
+
+// NewClient returns a fully initialized WebFinger Client. The remote.Options are
+// applied to every WebFinger and host-meta request (but not to the inner client).
+func NewClient(innerClient streams.Client, options ...remote.Option) *Client {
+	return &Client{
+		innerClient: innerClient,
+		options:     options,
+		scheme:      "https",
+	}
+}
+
+// Load returns the actor document for a handle, or passes any other URL to the inner client.
+// A context.Context in the options cancels the WebFinger lookup, as well as the inner Load.
+func (client *Client) Load(uri string, options ...any) (streams.Document, error) {
+
+	const location = "hannibal.webfinger.Client.Load"
+
+	if !IsHandle(uri) {
+		return client.innerClient.Load(uri, options...)
+	}
+
+	actorURL, err := client.ActorURL(uri, options...)
+
+	if err != nil {
+		return streams.NilDocument(), derp.Wrap(err, location, "Unable to resolve handle", uri)
+	}
+
+	return client.innerClient.Load(actorURL, options...)
+}
+
+// Save passes the document to the inner client.
+func (client *Client) Save(document streams.Document) error {
+	return client.innerClient.Save(document)
+}
+
+// Delete passes the document ID to the inner client.
+func (client *Client) Delete(documentID string) error {
+	return client.innerClient.Delete(documentID)
+}
+
+// SetRootClient passes the top-level client down to the underlying client.
+func (client *Client) SetRootClient(rootClient streams.Client) {
+	if client.innerClient != nil {
+		client.innerClient.SetRootClient(rootClient)
+	}
+}
+
+// ActorURL returns the ActivityPub actor URL for a handle. Options work the same way as in Lookup.
+func (client *Client) ActorURL(handle string, options ...any) (string, error) {
+
+	const location = "hannibal.webfinger.Client.ActorURL"
+
+	resource, err := client.Lookup(handle, options...)
+
+	if err != nil {
+		return "", derp.Wrap(err, location, "Unable to look up handle", handle)
+	}
+
+	actorURL := resource.ActorURL()
+
+	if actorURL == "" {
+		return "", derp.NotFound(location, "WebFinger resource does not link to an ActivityPub actor", handle)
+	}
+
+	return actorURL, nil
+}
+
+// Lookup returns the WebFinger Resource for a handle. It queries the server's standard
+// WebFinger endpoint first, then falls back to the LRDD template in its host-meta document.
+// A context.Context in the options cancels these requests along with it. All other options
+// are ignored.
+func (client *Client) Lookup(handle string, options ...any) (Resource, error) {
+
+	const location = "hannibal.webfinger.Client.Lookup"
+
+	requestOptions := contextOptions(options)
+
+	username, host, ok := ParseHandle(handle)
+
+	if !ok {
+		return Resource{}, derp.BadRequest(location, "Value is not a valid handle", handle)
+	}
+
+	resource := "acct:" + username + "@" + host
+
+	// Try the standard WebFinger endpoint first
+	result, err := client.loadResource(client.scheme+"://"+host+PathWebFinger+"?resource="+url.QueryEscape(resource), requestOptions)
+
+	if err == nil {
+		return result, nil
+	}
+
+	// Fall back to the server's host-meta document
+	template, hostMetaErr := client.lrddTemplate(host, requestOptions)
+
+	if hostMetaErr != nil {
+		return Resource{}, derp.Wrap(err, location, "Unable to load WebFinger resource", handle, hostMetaErr.Error())
+	}
+
+	result, err = client.loadResource(strings.ReplaceAll(template, "{uri}", url.QueryEscape(resource)), requestOptions)
+
+	if err != nil {
+		return Resource{}, derp.Wrap(err, location, "Unable to load WebFinger resource from host-meta template", handle, template)
+	}
+
+	return result, nil
+}
+
+// loadResource loads and parses a WebFinger Resource
+func (client *Client) loadResource(resourceURL string, requestOptions []remote.Option) (Resource, error) {
+
+	const location = "hannibal.webfinger.Client.loadResource"
+
+	body, err := client.get(resourceURL, vocab.ContentTypeJSONResourceDescriptor+", "+vocab.ContentTypeJSON, requestOptions)
+
+	if err != nil {
+		return Resource{}, derp.Wrap(err, location, "Unable to load WebFinger resource", resourceURL)
+	}
+
+	result := Resource{}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return Resource{}, derp.Wrap(err, location, "Unable to parse WebFinger resource", resourceURL)
+	}
+
+	return result, nil
+}
+
+// lrddTemplate returns the WebFinger URL template from a server's host-meta document,
+// which may be either XML (XRD) or JSON (JRD).
+func (client *Client) lrddTemplate(host string, requestOptions []remote.Option) (string, error) {
+
+	const location = "hannibal.webfinger.Client.lrddTemplate"
+
+	hostMetaURL := client.scheme + "://" + host + PathHostMeta
+	body, err := client.get(hostMetaURL, "application/xrd+xml, "+vocab.ContentTypeJSONResourceDescriptor, requestOptions)
+
+	if err != nil {
+		return "", derp.Wrap(err, location, "Unable to load host-meta", hostMetaURL)
+	}
+
+	hostMeta := struct {
+		Links []Link `json:"links" xml:"Link"`
+	}{}
+
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		err = json.Unmarshal(body, &hostMeta)
+	} else {
+		err = xml.Unmarshal(body, &hostMeta)
+	}
+
+	if err != nil {
+		return "", derp.Wrap(err, location, "Unable to parse host-meta", hostMetaURL)
+	}
+
+	for _, link := range hostMeta.Links {
+		if (link.Rel == RelationLRDD) && strings.Contains(link.Template, "{uri}") {
+			return link.Template, nil
+		}
+	}
+
+	return "", derp.NotFound(location, "host-meta does not include an LRDD template", hostMetaURL)
+}
+
+// get returns the body of a successful GET request. The requestOptions are applied after
+// the options that the client was created with.
+func (client *Client) get(uri string, accept string, requestOptions []remote.Option) ([]byte, error) {
+
+	const location = "hannibal.webfinger.Client.get"
+
+	var body []byte
+
+	transaction := remote.Get(uri).
+		Accept(accept).
+		With(client.options...).
+		With(requestOptions...).
+		With(httpbody.Read(&body, maxBodySize))
+
+	if err := transaction.Send(); err != nil {
+		return nil, derp.Wrap(err, location, "Unable to complete request", uri)
+	}
+
+	return body, nil
+}
+
+// contextOptions returns a remote.Option for each context.Context in a list of Load options,
+// so that WebFinger requests are cancelled along with them.
+func contextOptions(options []any) []remote.Option {
+
+	result := make([]remote.Option, 0, 1)
+
+	for _, option := range options {
+		if ctx, ok := option.(context.Context); ok {
+			result = append(result, streams.ContextOption(ctx))
+		}
+	}
+
+	return result
+}
+
+// Verify that Client satisfies the streams.Client interface.
+var _ streams.Client = &Client{}
@@ -0,0 +1,223 @@
+package webfinger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/streams"
+	"github.com/benpate/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockClient is a streams.Client that records the URLs that it loads
+type mockClient struct {
+	loaded []string
+}
+
+func (client *mockClient) SetRootClient(streams.Client) {}
+func (client *mockClient) Save(streams.Document) error  { return nil }
+func (client *mockClient) Delete(string) error          { return nil }
+
+func (client *mockClient) Load(uri string, options ...any) (streams.Document, error) {
+	client.loaded = append(client.loaded, uri)
+	return streams.NewDocument(map[string]any{"id": uri, "type": "Person"}), nil
+}
+
+// newTestClient returns a WebFinger Client that can reach the local test server over HTTP
+func newTestClient(inner streams.Client) *Client {
+
+	allowPrivateIPs := remote.Option{
+		BeforeRequest: func(txn *remote.Transaction) error {
+			txn.AllowPrivateIPs(true)
+			return nil
+		},
+	}
+
+	result := NewClient(inner, allowPrivateIPs)
+	result.scheme = "http"
+	return result
+}
+
+// newTestServer returns a test server (and its host name) that serves the provided handlers
+func newTestServer(t *testing.T, handlers map[string]http.HandlerFunc) (*httptest.Server, string) {
+
+	mux := http.NewServeMux()
+
+	for path, handler := range handlers {
+		mux.HandleFunc(path, handler)
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, strings.TrimPrefix(server.URL, "http://")
+}
+
+// webfingerHandler responds to WebFinger requests for "alice" on the provided host
+func webfingerHandler(host *string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Query().Get("resource") != "acct:alice@"+*host {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/jrd+json")
+		_, _ = w.Write([]byte(`{
+			"subject": "acct:alice@` + *host + `",
+			"links": [
+				{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": "https://example.social/@alice"},
+				{"rel": "self", "type": "application/activity+json", "href": "https://example.social/users/alice"}
+			]
+		}`))
+	}
+}
+
+// TestClient_Load confirms handles are resolved to actor URLs before loading, in every supported format.
+func TestClient_Load(t *testing.T) {
+
+	var host string
+	_, host = newTestServer(t, map[string]http.HandlerFunc{
+		PathWebFinger: webfingerHandler(&host),
+	})
+
+	inner := &mockClient{}
+	client := newTestClient(inner)
+
+	for _, handle := range []string{"@alice@" + host, "acct:alice@" + host, "alice@" + host} {
+		document, err := client.Load(handle)
+		require.NoError(t, err, handle)
+		assert.Equal(t, "https://example.social/users/alice", document.ID())
+	}
+
+	// Regular URLs pass straight through
+	_, err := client.Load("https://example.social/users/bob")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"https://example.social/users/alice",
+		"https://example.social/users/alice",
+		"https://example.social/users/alice",
+		"https://example.social/users/bob",
+	}, inner.loaded)
+}
+
+// TestClient_HostMeta confirms the host-meta LRDD template is used when the standard endpoint fails.
+func TestClient_HostMeta(t *testing.T) {
+
+	var host string
+	var server *httptest.Server
+
+	server, host = newTestServer(t, map[string]http.HandlerFunc{
+		"/custom/webfinger": webfingerHandler(&host),
+		PathHostMeta: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xrd+xml")
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+				<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
+					<Link rel="lrdd" template="` + server.URL + `/custom/webfinger?resource={uri}"/>
+				</XRD>`))
+		},
+	})
+
+	actorURL, err := newTestClient(&mockClient{}).ActorURL("@alice@" + host)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.social/users/alice", actorURL)
+}
+
+// TestClient_HostMetaJSON confirms JSON host-meta documents are supported, too.
+func TestClient_HostMetaJSON(t *testing.T) {
+
+	var host string
+	var server *httptest.Server
+
+	server, host = newTestServer(t, map[string]http.HandlerFunc{
+		"/custom/webfinger": webfingerHandler(&host),
+		PathHostMeta: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"links":[{"rel":"lrdd","template":"` + server.URL + `/custom/webfinger?resource={uri}"}]}`))
+		},
+	})
+
+	resource, err := newTestClient(&mockClient{}).Lookup("alice@" + host)
+	require.NoError(t, err)
+	assert.Equal(t, "acct:alice@"+host, resource.Subject)
+}
+
+// TestClient_NotFound confirms errors for unknown users, and for resources without an ActivityPub actor.
+func TestClient_NotFound(t *testing.T) {
+
+	var host string
+	_, host = newTestServer(t, map[string]http.HandlerFunc{
+		PathWebFinger: func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("resource") == "acct:nolinks@"+host {
+				w.Header().Set("Content-Type", "application/jrd+json")
+				_, _ = w.Write([]byte(`{"subject":"acct:nolinks@` + host + `","links":[{"rel":"self","type":"text/html","href":"https://example.social/nolinks"}]}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		},
+	})
+
+	inner := &mockClient{}
+	client := newTestClient(inner)
+
+	_, err := client.Load("@nobody@" + host)
+	require.Error(t, err)
+
+	_, err = client.ActorURL("@nolinks@" + host)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, derp.ErrorCode(err))
+
+	assert.Empty(t, inner.loaded)
+}
+
+// TestClient_Context confirms a context.Context in the Load options cancels the WebFinger lookup.
+func TestClient_Context(t *testing.T) {
+
+	requests := 0
+
+	var host string
+	_, host = newTestServer(t, map[string]http.HandlerFunc{
+		PathWebFinger: func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			webfingerHandler(&host)(w, r)
+		},
+	})
+
+	inner := &mockClient{}
+	client := newTestClient(inner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.Load("@alice@"+host, ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, requests)
+	assert.Empty(t, inner.loaded)
+
+	// Without the cancelled context, the same lookup succeeds
+	_, err = client.Load("@alice@" + host)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+// TestResource_ActorURL confirms the "self" link must have an ActivityPub content type.
+func TestResource_ActorURL(t *testing.T) {
+
+	resource := Resource{
+		Links: []Link{
+			{Rel: RelationSelf, Type: "text/html", Href: "https://example.social/@alice"},
+			{Rel: RelationProfilePage, Type: "application/activity+json", Href: "https://example.social/profile"},
+			{Rel: RelationSelf, Type: `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, Href: "https://example.social/users/alice"},
+		},
+	}
+
+	assert.Equal(t, "https://example.social/users/alice", resource.ActorURL())
+	assert.Equal(t, "", Resource{}.ActorURL())
+}
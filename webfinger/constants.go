@@ -0,0 +1,19 @@
+package webfinger
+
+// RelationSelf is the link relation for the resource's own (ActivityPub) representation
+const RelationSelf = "self"
+
+// RelationProfilePage is the link relation for the resource's human-readable profile page
+const RelationProfilePage = "http://webfinger.net/rel/profile-page"
+
+// RelationLRDD is the host-meta link relation whose template points to the WebFinger endpoint
+const RelationLRDD = "lrdd"
+
+// PathWebFinger is the standard location of a server's WebFinger endpoint
+const PathWebFinger = "/.well-known/webfinger"
+
+// PathHostMeta is the standard location of a server's host-meta document
+const PathHostMeta = "/.well-known/host-meta"
+
+// maxBodySize is the largest response that will be read from a WebFinger or host-meta request
+const maxBodySize = 1 << 20 // 1 MB
@@ -0,0 +1,4 @@
+// Package webfinger resolves "@user@domain" handles into ActivityPub actor URLs using
+// WebFinger (RFC 7033), with a fallback to host-meta (RFC 6415) for servers that
+// publish their WebFinger endpoint at a non-standard path.
+package webfinger
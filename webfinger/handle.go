@@ -0,0 +1,36 @@
+package webfinger
+
+import "strings"
+
+// ParseHandle splits a fediverse handle into its username and host. It accepts
+// "@alice@example.social", "alice@example.social", and "acct:alice@example.social".
+// The final value is FALSE if the value is not a handle (for instance, if it is a URL).
+func ParseHandle(value string) (username string, host string, ok bool) {
+
+	value = strings.TrimSpace(value)
+
+	if len(value) > 5 && strings.EqualFold(value[:5], "acct:") {
+		value = value[5:]
+	}
+
+	value = strings.TrimPrefix(value, "@")
+
+	username, host, found := strings.Cut(value, "@")
+
+	if !found || (username == "") || (host == "") {
+		return "", "", false
+	}
+
+	// Usernames and host names cannot include URL punctuation (but hosts may include a port)
+	if strings.ContainsAny(username, "/?#:@ ") || strings.ContainsAny(host, "/?#@ ") {
+		return "", "", false
+	}
+
+	return username, strings.ToLower(host), true
+}
+
+// IsHandle returns TRUE if the value is a fediverse handle, rather than a URL
+func IsHandle(value string) bool {
+	_, _, ok := ParseHandle(value)
+	return ok
+}
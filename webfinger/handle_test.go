@@ -0,0 +1,31 @@
+package webfinger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseHandle confirms each supported handle format, and that URLs are not handles.
+func TestParseHandle(t *testing.T) {
+
+	check := func(value string, expectedUsername string, expectedHost string, expectedOK bool) {
+		username, host, ok := ParseHandle(value)
+		assert.Equal(t, expectedUsername, username, value)
+		assert.Equal(t, expectedHost, host, value)
+		assert.Equal(t, expectedOK, ok, value)
+	}
+
+	check("@alice@example.social", "alice", "example.social", true)
+	check("alice@Example.Social", "alice", "example.social", true)
+	check("acct:alice@example.social", "alice", "example.social", true)
+	check("ACCT:alice@example.social", "alice", "example.social", true)
+	check(" @alice@localhost:8080 ", "alice", "localhost:8080", true)
+
+	check("https://example.social/@alice", "", "", false)
+	check("https://alice@example.social/", "", "", false)
+	check("@alice", "", "", false)
+	check("alice@", "", "", false)
+	check("@@example.social", "", "", false)
+	check("", "", "", false)
+}
@@ -0,0 +1,38 @@
+package webfinger
+
+import (
+	"github.com/benpate/hannibal"
+)
+
+// Resource is a JSON Resource Descriptor (JRD) returned by a WebFinger server
+// https://datatracker.ietf.org/doc/html/rfc7033#section-4.4
+type Resource struct {
+	Subject    string            `json:"subject"`
+	Aliases    []string          `json:"aliases,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Links      []Link            `json:"links,omitempty"`
+}
+
+// Link is a single link in a WebFinger Resource, or in a host-meta document
+// https://datatracker.ietf.org/doc/html/rfc7033#section-4.4.4
+type Link struct {
+	Rel        string            `json:"rel"                  xml:"rel,attr"`
+	Type       string            `json:"type,omitempty"       xml:"type,attr,omitempty"`
+	Href       string            `json:"href,omitempty"       xml:"href,attr,omitempty"`
+	Template   string            `json:"template,omitempty"   xml:"template,attr,omitempty"`
+	Titles     map[string]string `json:"titles,omitempty"     xml:"-"`
+	Properties map[string]string `json:"properties,omitempty" xml:"-"`
+}
+
+// ActorURL returns the URL of the ActivityPub actor described by this Resource,
+// which is the first "self" link with an ActivityPub content type.
+func (resource Resource) ActorURL() string {
+
+	for _, link := range resource.Links {
+		if (link.Rel == RelationSelf) && (link.Href != "") && hannibal.IsActivityPubContentType(link.Type) {
+			return link.Href
+		}
+	}
+
+	return ""
+}
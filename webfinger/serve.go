@@ -0,0 +1,92 @@
+package webfinger
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/benpate/derp"
+	"github.com/benpate/hannibal/vocab"
+	"github.com/labstack/echo/v4"
+)
+
+// Serve answers a WebFinger request (GET /.well-known/webfinger?resource=...) using the
+// provided LookupFunc to find the requested account. It responds with "400 Bad Request"
+// if the resource is missing or invalid, "404 Not Found" if the account does not exist,
+// and otherwise a JSON Resource Descriptor that includes only the requested "rel" links
+// (if any). All responses can be read from other origins (CORS).
+func Serve(ctx echo.Context, lookup LookupFunc) error {
+
+	const location = "hannibal.webfinger.Serve"
+
+	// WebFinger resources are public, and must be available to browser-based clients
+	// https://datatracker.ietf.org/doc/html/rfc7033#section-5
+	ctx.Response().Header().Set(echo.HeaderAccessControlAllowOrigin, "*")
+
+	resource, ok := normalizeResource(ctx.QueryParam("resource"))
+
+	if !ok {
+		return ctx.String(http.StatusBadRequest, "WebFinger requests must include a valid 'resource' parameter")
+	}
+
+	account, err := lookup(resource)
+
+	if err != nil {
+
+		if derp.ErrorCode(err) == http.StatusNotFound {
+			return ctx.String(http.StatusNotFound, "Resource not found")
+		}
+
+		return derp.Wrap(err, location, "Unable to look up WebFinger resource", resource)
+	}
+
+	if account.ActorID == "" {
+		return ctx.String(http.StatusNotFound, "Resource not found")
+	}
+
+	result := account.Resource(resource)
+	result.Links = filterLinks(result.Links, ctx.QueryParams()["rel"])
+
+	ctx.Response().Header().Set(echo.HeaderContentType, vocab.ContentTypeJSONResourceDescriptor)
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// normalizeResource validates a requested resource. Handles are converted to
+// "acct:username@host" and HTTP(S) URLs are returned unchanged.
+func normalizeResource(resource string) (string, bool) {
+
+	resource = strings.TrimSpace(resource)
+
+	if username, host, ok := ParseHandle(resource); ok {
+		return "acct:" + username + "@" + host, true
+	}
+
+	if parsed, err := url.Parse(resource); err == nil {
+		if ((parsed.Scheme == "http") || (parsed.Scheme == "https")) && (parsed.Host != "") {
+			return resource, true
+		}
+	}
+
+	return "", false
+}
+
+// filterLinks returns only the links whose relation was requested. If no
+// relations were requested, then all links are returned.
+// https://datatracker.ietf.org/doc/html/rfc7033#section-4.3
+func filterLinks(links []Link, relations []string) []Link {
+
+	if len(relations) == 0 {
+		return links
+	}
+
+	result := make([]Link, 0, len(links))
+
+	for _, link := range links {
+		if slices.Contains(relations, link.Rel) {
+			result = append(result, link)
+		}
+	}
+
+	return result
+}
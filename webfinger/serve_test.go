@@ -0,0 +1,120 @@
+package webfinger
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benpate/derp"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveTestLookup knows a single account: alice@example.social
+func serveTestLookup(resource string) (Account, error) {
+
+	switch resource {
+
+	case "acct:alice@example.social", "https://example.social/users/alice":
+		return Account{
+			Subject:    "acct:alice@example.social",
+			ActorID:    "https://example.social/users/alice",
+			ProfileURL: "https://example.social/@alice",
+		}, nil
+
+	case "acct:broken@example.social":
+		return Account{}, errors.New("database is down")
+	}
+
+	return Account{}, derp.NotFound("webfinger.serveTestLookup", "Unknown resource", resource)
+}
+
+// serveTest sends a WebFinger request to Serve and returns the recorded response
+func serveTest(target string) (*httptest.ResponseRecorder, error) {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	recorder := httptest.NewRecorder()
+	err := Serve(echo.New().NewContext(request, recorder), serveTestLookup)
+	return recorder, err
+}
+
+// TestServe confirms a known account returns a complete JRD with CORS headers.
+func TestServe(t *testing.T) {
+
+	recorder, err := serveTest("/.well-known/webfinger?resource=acct:alice@Example.Social")
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/jrd+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+
+	resource := Resource{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resource))
+
+	assert.Equal(t, "acct:alice@example.social", resource.Subject)
+	assert.Equal(t, []string{"https://example.social/users/alice", "https://example.social/@alice"}, resource.Aliases)
+	assert.Equal(t, "https://example.social/users/alice", resource.ActorURL())
+	require.Len(t, resource.Links, 2)
+	assert.Equal(t, RelationProfilePage, resource.Links[1].Rel)
+}
+
+// TestServe_URLResource confirms actors can also be looked up by URL.
+func TestServe_URLResource(t *testing.T) {
+
+	recorder, err := serveTest("/.well-known/webfinger?resource=https%3A%2F%2Fexample.social%2Fusers%2Falice")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+// TestServe_Rel confirms the "rel" parameter filters the returned links.
+func TestServe_Rel(t *testing.T) {
+
+	recorder, err := serveTest("/.well-known/webfinger?resource=acct:alice@example.social&rel=self&rel=http://example.com/unknown")
+	require.NoError(t, err)
+
+	resource := Resource{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resource))
+
+	require.Len(t, resource.Links, 1)
+	assert.Equal(t, RelationSelf, resource.Links[0].Rel)
+
+	// Filtering does not affect the subject or aliases
+	assert.Equal(t, "acct:alice@example.social", resource.Subject)
+	assert.Len(t, resource.Aliases, 2)
+}
+
+// TestServe_Errors confirms the status codes for invalid requests, unknown accounts, and lookup failures.
+func TestServe_Errors(t *testing.T) {
+
+	recorder, err := serveTest("/.well-known/webfinger")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+
+	recorder, err = serveTest("/.well-known/webfinger?resource=not-a-resource")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder, err = serveTest("/.well-known/webfinger?resource=acct:bob@example.social")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	_, err = serveTest("/.well-known/webfinger?resource=acct:broken@example.social")
+	require.Error(t, err)
+}
+
+// TestAccount_Resource confirms the defaults used when converting an Account into a Resource.
+func TestAccount_Resource(t *testing.T) {
+
+	resource := Account{
+		ActorID: "https://example.social/users/bob",
+		Links:   []Link{{Rel: "http://webfinger.net/rel/avatar", Type: "image/png", Href: "https://example.social/bob.png"}},
+	}.Resource("acct:bob@example.social")
+
+	assert.Equal(t, "acct:bob@example.social", resource.Subject)
+	assert.Equal(t, []string{"https://example.social/users/bob"}, resource.Aliases)
+	require.Len(t, resource.Links, 2)
+	assert.Equal(t, "http://webfinger.net/rel/avatar", resource.Links[1].Rel)
+}